@@ -2,6 +2,8 @@ package ast
 
 import (
 	"bytes"
+	"strconv"
+	"strings"
 
 	"ljos.app/interpreter/token"
 )
@@ -9,6 +11,8 @@ import (
 type Node interface {
 	TokenLiteral() string
 	String() string
+	Pos() token.Position // position of the first character of the node
+	End() token.Position // position immediately after the node
 }
 
 type Statement interface {
@@ -23,6 +27,7 @@ type Expression interface {
 
 type Program struct {
 	Statements []Statement
+	Comments   []*Comment // all comments in the source, in order
 }
 
 func (p *Program) String() string {
@@ -40,6 +45,47 @@ func (p *Program) TokenLiteral() string {
 	return ""
 }
 
+func (p *Program) Pos() token.Position {
+	if len(p.Statements) > 0 {
+		return p.Statements[0].Pos()
+	}
+	return token.Position{}
+}
+
+func (p *Program) End() token.Position {
+	if len(p.Statements) > 0 {
+		return p.Statements[len(p.Statements)-1].End()
+	}
+	return token.Position{}
+}
+
+// Comment is a // line comment or /* */ block comment. Comments are not part
+// of the statement tree, they are kept on the Program so that tools like a
+// formatter can put them back.
+type Comment struct {
+	Token token.Token // the token.COMMENT token, including the comment markers
+}
+
+func (c *Comment) TokenLiteral() string {
+	return c.Token.Literal
+}
+func (c *Comment) Pos() token.Position { return c.Token.Pos }
+func (c *Comment) End() token.Position { return c.Token.End }
+
+func (c *Comment) String() string {
+	return c.Token.Literal
+}
+
+// Text returns the comment without its markers.
+func (c *Comment) Text() string {
+	text := c.Token.Literal
+	if strings.HasPrefix(text, "//") {
+		return text[2:]
+	}
+	text = strings.TrimPrefix(text, "/*")
+	return strings.TrimSuffix(text, "*/")
+}
+
 type LetStatement struct {
 	Token token.Token // the token.Let token
 	Name  *Identifier
@@ -50,6 +96,13 @@ func (ls *LetStatement) statementNode() {}
 func (ls *LetStatement) TokenLiteral() string {
 	return ls.Token.Literal
 }
+func (ls *LetStatement) Pos() token.Position { return ls.Token.Pos }
+func (ls *LetStatement) End() token.Position {
+	if ls.Value != nil {
+		return ls.Value.End()
+	}
+	return ls.Name.End()
+}
 
 func (ls *LetStatement) String() string {
 	var out bytes.Buffer
@@ -72,23 +125,40 @@ func (i *Identifier) expressionNode() {}
 func (i *Identifier) TokenLiteral() string {
 	return i.Token.Literal
 }
+func (i *Identifier) Pos() token.Position { return i.Token.Pos }
+func (i *Identifier) End() token.Position { return i.Token.End }
 
 func (i *Identifier) String() string {
 	return i.Value
 }
 
+// IfStatement is both a statement and an expression, so that
+// `let x = if (a) { 1 } else { 2 };` evaluates to the value of the taken branch.
 type IfStatement struct {
 	Token     token.Token // the token.IfStatement Token
 	Condition Expression
-	Value     Expression
-	ElseValue Expression
+	Value     *BlockStatement
+	ElseValue *BlockStatement
 	ElseIf    *IfStatement
 }
 
-func (i *IfStatement) statementNode() {}
+func (i *IfStatement) statementNode()  {}
+func (i *IfStatement) expressionNode() {}
 func (i *IfStatement) TokenLiteral() string {
 	return i.Token.Literal
 }
+func (i *IfStatement) Pos() token.Position { return i.Token.Pos }
+func (i *IfStatement) End() token.Position {
+	switch {
+	case i.ElseValue != nil:
+		return i.ElseValue.End()
+	case i.ElseIf != nil:
+		return i.ElseIf.End()
+	case i.Value != nil:
+		return i.Value.End()
+	}
+	return i.Token.End
+}
 
 func (is *IfStatement) String() string {
 	var out bytes.Buffer
@@ -102,6 +172,10 @@ func (is *IfStatement) String() string {
 		out.WriteString(is.Value.String())
 	}
 	out.WriteString("}")
+	if is.ElseIf != nil {
+		out.WriteString(" else ")
+		out.WriteString(is.ElseIf.String())
+	}
 	if is.ElseValue != nil {
 		out.WriteString(" else {")
 		out.WriteString(is.ElseValue.String())
@@ -111,6 +185,111 @@ func (is *IfStatement) String() string {
 	return out.String()
 }
 
+type BlockStatement struct {
+	Token      token.Token // the { token
+	Statements []Statement
+	Rbrace     token.Token // the closing }, unset for the body of an expression lambda
+}
+
+func (bs *BlockStatement) statementNode() {}
+func (bs *BlockStatement) TokenLiteral() string {
+	return bs.Token.Literal
+}
+func (bs *BlockStatement) Pos() token.Position { return bs.Token.Pos }
+func (bs *BlockStatement) End() token.Position {
+	if bs.Rbrace.End.IsValid() {
+		return bs.Rbrace.End
+	}
+	if len(bs.Statements) > 0 {
+		return bs.Statements[len(bs.Statements)-1].End()
+	}
+	return bs.Token.End
+}
+
+func (bs *BlockStatement) String() string {
+	var out bytes.Buffer
+	for _, s := range bs.Statements {
+		out.WriteString(s.String())
+	}
+	return out.String()
+}
+
+// WhileStatement repeats Body for as long as Condition is truthy.
+type WhileStatement struct {
+	Token     token.Token // the while token
+	Condition Expression
+	Body      *BlockStatement
+}
+
+func (ws *WhileStatement) statementNode() {}
+func (ws *WhileStatement) TokenLiteral() string {
+	return ws.Token.Literal
+}
+func (ws *WhileStatement) Pos() token.Position { return ws.Token.Pos }
+func (ws *WhileStatement) End() token.Position { return ws.Body.End() }
+
+func (ws *WhileStatement) String() string {
+	var out bytes.Buffer
+	out.WriteString("while ")
+	out.WriteString(ws.Condition.String())
+	out.WriteString(" {")
+	out.WriteString(ws.Body.String())
+	out.WriteString("}")
+	return out.String()
+}
+
+// ForStatement is `for (x in iterable) { ... }`, binding Variable to each
+// element of an array, key of a hash or character of a string.
+type ForStatement struct {
+	Token    token.Token // the for token
+	Variable *Identifier
+	Iterable Expression
+	Body     *BlockStatement
+}
+
+func (fs *ForStatement) statementNode() {}
+func (fs *ForStatement) TokenLiteral() string {
+	return fs.Token.Literal
+}
+func (fs *ForStatement) Pos() token.Position { return fs.Token.Pos }
+func (fs *ForStatement) End() token.Position { return fs.Body.End() }
+
+func (fs *ForStatement) String() string {
+	var out bytes.Buffer
+	out.WriteString("for (")
+	out.WriteString(fs.Variable.String())
+	out.WriteString(" in ")
+	out.WriteString(fs.Iterable.String())
+	out.WriteString(") {")
+	out.WriteString(fs.Body.String())
+	out.WriteString("}")
+	return out.String()
+}
+
+type BreakStatement struct {
+	Token token.Token
+}
+
+func (bs *BreakStatement) statementNode() {}
+func (bs *BreakStatement) TokenLiteral() string {
+	return bs.Token.Literal
+}
+func (bs *BreakStatement) Pos() token.Position { return bs.Token.Pos }
+func (bs *BreakStatement) End() token.Position { return bs.Token.End }
+func (bs *BreakStatement) String() string      { return "break;" }
+
+type ContinueStatement struct {
+	Token token.Token
+}
+
+func (cs *ContinueStatement) statementNode() {}
+func (cs *ContinueStatement) TokenLiteral() string {
+	return cs.Token.Literal
+}
+func (cs *ContinueStatement) Pos() token.Position { return cs.Token.Pos }
+func (cs *ContinueStatement) End() token.Position { return cs.Token.End }
+func (cs *ContinueStatement) String() string      { return "continue;" }
+
 type ReturnStatement struct {
 	Token token.Token
 	Value Expression
@@ -120,6 +299,13 @@ func (rs *ReturnStatement) statementNode() {}
 func (rs *ReturnStatement) TokenLiteral() string {
 	return rs.Token.Literal
 }
+func (rs *ReturnStatement) Pos() token.Position { return rs.Token.Pos }
+func (rs *ReturnStatement) End() token.Position {
+	if rs.Value != nil {
+		return rs.Value.End()
+	}
+	return rs.Token.End
+}
 
 func (rs *ReturnStatement) String() string {
 	var out bytes.Buffer
@@ -143,6 +329,13 @@ func (rs *ExpressionStatement) statementNode() {}
 func (rs *ExpressionStatement) TokenLiteral() string {
 	return rs.Token.Literal
 }
+func (es *ExpressionStatement) Pos() token.Position { return es.Token.Pos }
+func (es *ExpressionStatement) End() token.Position {
+	if es.Expression != nil {
+		return es.Expression.End()
+	}
+	return es.Token.End
+}
 
 func (es *ExpressionStatement) String() string {
 	if es.Expression != nil {
@@ -150,3 +343,420 @@ func (es *ExpressionStatement) String() string {
 	}
 	return ""
 }
+
+type IntegerLiteral struct {
+	Token token.Token
+	Value int64
+}
+
+func (il *IntegerLiteral) expressionNode() {}
+func (il *IntegerLiteral) TokenLiteral() string {
+	return il.Token.Literal
+}
+func (il *IntegerLiteral) Pos() token.Position { return il.Token.Pos }
+func (il *IntegerLiteral) End() token.Position { return il.Token.End }
+
+func (il *IntegerLiteral) String() string {
+	return il.Token.Literal
+}
+
+type Boolean struct {
+	Token token.Token
+	Value bool
+}
+
+func (b *Boolean) expressionNode() {}
+func (b *Boolean) TokenLiteral() string {
+	return b.Token.Literal
+}
+func (b *Boolean) Pos() token.Position { return b.Token.Pos }
+func (b *Boolean) End() token.Position { return b.Token.End }
+
+func (b *Boolean) String() string {
+	return b.Token.Literal
+}
+
+type FloatLiteral struct {
+	Token token.Token
+	Value float64
+}
+
+func (fl *FloatLiteral) expressionNode() {}
+func (fl *FloatLiteral) TokenLiteral() string {
+	return fl.Token.Literal
+}
+func (fl *FloatLiteral) Pos() token.Position { return fl.Token.Pos }
+func (fl *FloatLiteral) End() token.Position { return fl.Token.End }
+
+func (fl *FloatLiteral) String() string {
+	return fl.Token.Literal
+}
+
+type StringLiteral struct {
+	Token token.Token
+	Value string // the contents with escape sequences decoded
+}
+
+func (sl *StringLiteral) expressionNode() {}
+func (sl *StringLiteral) TokenLiteral() string {
+	return sl.Token.Literal
+}
+func (sl *StringLiteral) Pos() token.Position { return sl.Token.Pos }
+func (sl *StringLiteral) End() token.Position { return sl.Token.End }
+
+func (sl *StringLiteral) String() string {
+	return strconv.Quote(sl.Value)
+}
+
+type PrefixExpression struct {
+	Token    token.Token // the prefix token, e.g. ! or -
+	Operator string
+	Right    Expression
+}
+
+func (pe *PrefixExpression) expressionNode() {}
+func (pe *PrefixExpression) TokenLiteral() string {
+	return pe.Token.Literal
+}
+func (pe *PrefixExpression) Pos() token.Position { return pe.Token.Pos }
+func (pe *PrefixExpression) End() token.Position {
+	if pe.Right != nil {
+		return pe.Right.End()
+	}
+	return pe.Token.End
+}
+
+func (pe *PrefixExpression) String() string {
+	var out bytes.Buffer
+	out.WriteString("(")
+	out.WriteString(pe.Operator)
+	if pe.Right != nil {
+		out.WriteString(pe.Right.String())
+	}
+	out.WriteString(")")
+	return out.String()
+}
+
+type InfixExpression struct {
+	Token    token.Token // the operator token, e.g. +
+	Left     Expression
+	Operator string
+	Right    Expression
+}
+
+func (ie *InfixExpression) expressionNode() {}
+func (ie *InfixExpression) TokenLiteral() string {
+	return ie.Token.Literal
+}
+func (ie *InfixExpression) Pos() token.Position {
+	if ie.Left != nil {
+		return ie.Left.Pos()
+	}
+	return ie.Token.Pos
+}
+func (ie *InfixExpression) End() token.Position {
+	if ie.Right != nil {
+		return ie.Right.End()
+	}
+	return ie.Token.End
+}
+
+func (ie *InfixExpression) String() string {
+	var out bytes.Buffer
+	out.WriteString("(")
+	if ie.Left != nil {
+		out.WriteString(ie.Left.String())
+	}
+	out.WriteString(" " + ie.Operator + " ")
+	if ie.Right != nil {
+		out.WriteString(ie.Right.String())
+	}
+	out.WriteString(")")
+	return out.String()
+}
+
+// AssignExpression rebinds an existing variable or stores into an array
+// element or hash entry, either plainly with = or with a compound operator like +=
+// that combines it with its current value.
+type AssignExpression struct {
+	Token    token.Token // the assignment operator token
+	Target   Expression  // an Identifier, IndexExpression or MemberExpression
+	Operator string
+	Value    Expression
+}
+
+func (ae *AssignExpression) expressionNode() {}
+func (ae *AssignExpression) TokenLiteral() string {
+	return ae.Token.Literal
+}
+func (ae *AssignExpression) Pos() token.Position { return ae.Target.Pos() }
+func (ae *AssignExpression) End() token.Position {
+	if ae.Value != nil {
+		return ae.Value.End()
+	}
+	return ae.Token.End
+}
+
+func (ae *AssignExpression) String() string {
+	var out bytes.Buffer
+	out.WriteString(ae.Target.String())
+	out.WriteString(" " + ae.Operator + " ")
+	if ae.Value != nil {
+		out.WriteString(ae.Value.String())
+	}
+	return out.String()
+}
+
+// FunctionLiteral is either a `fn(x, y) { ... }` literal or an arrow lambda
+// `(x, y) => x + y`. Lambdas with an expression body get a synthesized block
+// holding a single expression statement.
+type FunctionLiteral struct {
+	Token      token.Token    // the fn token, or the => token for arrow lambdas
+	Lparen     token.Position // position of the ( opening a lambda's parameters
+	Parameters []*Identifier
+	Body       *BlockStatement
+}
+
+func (fl *FunctionLiteral) expressionNode() {}
+func (fl *FunctionLiteral) TokenLiteral() string {
+	return fl.Token.Literal
+}
+func (fl *FunctionLiteral) Pos() token.Position {
+	if fl.IsLambda() {
+		return fl.Lparen
+	}
+	return fl.Token.Pos
+}
+func (fl *FunctionLiteral) End() token.Position {
+	if fl.Body != nil {
+		return fl.Body.End()
+	}
+	return fl.Token.End
+}
+
+func (fl *FunctionLiteral) IsLambda() bool {
+	return fl.Token.Type == token.LAMBDA
+}
+
+func (fl *FunctionLiteral) String() string {
+	var out bytes.Buffer
+	params := []string{}
+	for _, p := range fl.Parameters {
+		params = append(params, p.String())
+	}
+	if fl.IsLambda() {
+		out.WriteString("(")
+		out.WriteString(strings.Join(params, ", "))
+		out.WriteString(") => ")
+		if fl.Body != nil && fl.Body.Token.Type != token.LBRACE {
+			out.WriteString(fl.Body.String())
+			return out.String()
+		}
+	} else {
+		out.WriteString(fl.TokenLiteral())
+		out.WriteString("(")
+		out.WriteString(strings.Join(params, ", "))
+		out.WriteString(") ")
+	}
+	out.WriteString("{")
+	if fl.Body != nil {
+		out.WriteString(fl.Body.String())
+	}
+	out.WriteString("}")
+	return out.String()
+}
+
+type CallExpression struct {
+	Token     token.Token // the ( token
+	Function  Expression  // Identifier, FunctionLiteral or MemberExpression
+	Arguments []Expression
+	Rparen    token.Token
+}
+
+func (ce *CallExpression) expressionNode() {}
+func (ce *CallExpression) TokenLiteral() string {
+	return ce.Token.Literal
+}
+func (ce *CallExpression) Pos() token.Position { return ce.Function.Pos() }
+func (ce *CallExpression) End() token.Position { return ce.Rparen.End }
+
+func (ce *CallExpression) String() string {
+	var out bytes.Buffer
+	args := []string{}
+	for _, a := range ce.Arguments {
+		args = append(args, a.String())
+	}
+	out.WriteString(ce.Function.String())
+	out.WriteString("(")
+	out.WriteString(strings.Join(args, ", "))
+	out.WriteString(")")
+	return out.String()
+}
+
+// MemberExpression is `obj.name`, looking up a hash field or a builtin
+// method of obj. A method call `xs.push(1)` is a CallExpression whose
+// Function is a MemberExpression.
+type MemberExpression struct {
+	Token    token.Token // the . token
+	Object   Expression
+	Property *Identifier
+}
+
+func (me *MemberExpression) expressionNode() {}
+func (me *MemberExpression) TokenLiteral() string {
+	return me.Token.Literal
+}
+func (me *MemberExpression) Pos() token.Position { return me.Object.Pos() }
+func (me *MemberExpression) End() token.Position { return me.Property.End() }
+
+func (me *MemberExpression) String() string {
+	return "(" + me.Object.String() + "." + me.Property.String() + ")"
+}
+
+type ArrayLiteral struct {
+	Token    token.Token // the [ token
+	Elements []Expression
+	Rbrack   token.Token
+}
+
+func (al *ArrayLiteral) expressionNode() {}
+func (al *ArrayLiteral) TokenLiteral() string {
+	return al.Token.Literal
+}
+func (al *ArrayLiteral) Pos() token.Position { return al.Token.Pos }
+func (al *ArrayLiteral) End() token.Position { return al.Rbrack.End }
+
+func (al *ArrayLiteral) String() string {
+	var out bytes.Buffer
+	elements := []string{}
+	for _, el := range al.Elements {
+		elements = append(elements, el.String())
+	}
+	out.WriteString("[")
+	out.WriteString(strings.Join(elements, ", "))
+	out.WriteString("]")
+	return out.String()
+}
+
+// HashPair is a single key: value entry of a HashLiteral.
+type HashPair struct {
+	Key   Expression
+	Value Expression
+}
+
+// HashLiteral is a `{"key": value}` literal. Pairs are kept in source order.
+type HashLiteral struct {
+	Token  token.Token // the { token
+	Pairs  []HashPair
+	Rbrace token.Token
+}
+
+func (hl *HashLiteral) expressionNode() {}
+func (hl *HashLiteral) TokenLiteral() string {
+	return hl.Token.Literal
+}
+func (hl *HashLiteral) Pos() token.Position { return hl.Token.Pos }
+func (hl *HashLiteral) End() token.Position { return hl.Rbrace.End }
+
+func (hl *HashLiteral) String() string {
+	var out bytes.Buffer
+	pairs := []string{}
+	for _, pair := range hl.Pairs {
+		pairs = append(pairs, pair.Key.String()+": "+pair.Value.String())
+	}
+	out.WriteString("{")
+	out.WriteString(strings.Join(pairs, ", "))
+	out.WriteString("}")
+	return out.String()
+}
+
+type IndexExpression struct {
+	Token  token.Token // the [ token
+	Left   Expression
+	Index  Expression
+	Rbrack token.Token
+}
+
+func (ie *IndexExpression) expressionNode() {}
+func (ie *IndexExpression) TokenLiteral() string {
+	return ie.Token.Literal
+}
+func (ie *IndexExpression) Pos() token.Position { return ie.Left.Pos() }
+func (ie *IndexExpression) End() token.Position { return ie.Rbrack.End }
+
+func (ie *IndexExpression) String() string {
+	var out bytes.Buffer
+	out.WriteString("(")
+	out.WriteString(ie.Left.String())
+	out.WriteString("[")
+	out.WriteString(ie.Index.String())
+	out.WriteString("])")
+	return out.String()
+}
+
+// SliceExpression is `xs[low:high]`. Either bound may be left out, in which
+// case Low or High is nil.
+type SliceExpression struct {
+	Token  token.Token // the [ token
+	Left   Expression
+	Low    Expression
+	High   Expression
+	Rbrack token.Token
+}
+
+func (se *SliceExpression) expressionNode() {}
+func (se *SliceExpression) TokenLiteral() string {
+	return se.Token.Literal
+}
+func (se *SliceExpression) Pos() token.Position { return se.Left.Pos() }
+func (se *SliceExpression) End() token.Position { return se.Rbrack.End }
+
+func (se *SliceExpression) String() string {
+	var out bytes.Buffer
+	out.WriteString("(")
+	out.WriteString(se.Left.String())
+	out.WriteString("[")
+	if se.Low != nil {
+		out.WriteString(se.Low.String())
+	}
+	out.WriteString(":")
+	if se.High != nil {
+		out.WriteString(se.High.String())
+	}
+	out.WriteString("])")
+	return out.String()
+}
+
+// BadStatement is a placeholder for a statement that could not be parsed,
+// covering the tokens skipped while recovering from the error.
+type BadStatement struct {
+	From token.Token
+	To   token.Token
+}
+
+func (bs *BadStatement) statementNode() {}
+func (bs *BadStatement) TokenLiteral() string {
+	return bs.From.Literal
+}
+func (bs *BadStatement) Pos() token.Position { return bs.From.Pos }
+func (bs *BadStatement) End() token.Position { return bs.To.End }
+
+func (bs *BadStatement) String() string {
+	return "<bad statement>"
+}
+
+// BadExpression is a placeholder for a missing or malformed expression.
+type BadExpression struct {
+	Token token.Token // the token where an expression was expected
+}
+
+func (be *BadExpression) expressionNode() {}
+func (be *BadExpression) TokenLiteral() string {
+	return be.Token.Literal
+}
+func (be *BadExpression) Pos() token.Position { return be.Token.Pos }
+func (be *BadExpression) End() token.Position { return be.Token.End }
+
+func (be *BadExpression) String() string {
+	return "<bad expression>"
+}
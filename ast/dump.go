@@ -0,0 +1,107 @@
+package ast
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+
+	"ljos.app/interpreter/token"
+)
+
+var (
+	nodeType     = reflect.TypeOf((*Node)(nil)).Elem()
+	tokenType    = reflect.TypeOf(token.Token{})
+	positionType = reflect.TypeOf(token.Position{})
+)
+
+// Dump writes node and its children to w as an indented tree, one node per
+// line with its position and any scalar fields:
+//
+//	Program 1:1
+//	  Statements[0]: LetStatement 1:1
+//	    Name: Identifier 1:5 Value="x"
+//	    Value: IntegerLiteral 1:9 Value=5
+//
+// Tokens are left out, as they repeat what the fields already show.
+func Dump(w io.Writer, node Node) error {
+	d := &dumper{w: w}
+	d.dump("", "", reflect.ValueOf(node))
+	return d.err
+}
+
+type dumper struct {
+	w   io.Writer
+	err error
+}
+
+func (d *dumper) printf(format string, args ...any) {
+	if d.err == nil {
+		_, d.err = fmt.Fprintf(d.w, format, args...)
+	}
+}
+
+// dump prints v, which is a node or a struct holding nodes like HashPair,
+// followed by its children indented one level deeper.
+func (d *dumper) dump(indent, label string, v reflect.Value) {
+	for v.Kind() == reflect.Interface || v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return
+		}
+		if v.Type().Implements(nodeType) && v.Kind() == reflect.Pointer {
+			break
+		}
+		v = v.Elem()
+	}
+
+	header := []string{label + typeName(v)}
+	if node, ok := v.Interface().(Node); ok {
+		header = append(header, node.Pos().String())
+		if c, ok := node.(*Comment); ok {
+			header = append(header, fmt.Sprintf("Text=%q", c.Text()))
+		}
+	}
+	s := reflect.Indirect(v)
+	var children []reflect.StructField
+	for i := 0; i < s.NumField(); i++ {
+		field := s.Type().Field(i)
+		switch {
+		case field.Type == tokenType || field.Type == positionType:
+		case isScalar(field.Type):
+			header = append(header, fmt.Sprintf("%s=%s", field.Name, formatScalar(s.Field(i))))
+		default:
+			children = append(children, field)
+		}
+	}
+	d.printf("%s%s\n", indent, strings.Join(header, " "))
+
+	for _, field := range children {
+		child := s.FieldByIndex(field.Index)
+		if child.Kind() == reflect.Slice {
+			for i := 0; i < child.Len(); i++ {
+				d.dump(indent+"  ", fmt.Sprintf("%s[%d]: ", field.Name, i), child.Index(i))
+			}
+			continue
+		}
+		d.dump(indent+"  ", field.Name+": ", child)
+	}
+}
+
+func typeName(v reflect.Value) string {
+	return reflect.Indirect(v).Type().Name()
+}
+
+func isScalar(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.String, reflect.Bool, reflect.Int, reflect.Int64, reflect.Float64:
+		return true
+	}
+	return false
+}
+
+func formatScalar(v reflect.Value) string {
+	if v.Kind() == reflect.String {
+		return fmt.Sprintf("%q", v.String())
+	}
+	return fmt.Sprint(v.Interface())
+}
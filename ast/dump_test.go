@@ -0,0 +1,43 @@
+package ast_test
+
+import (
+	"bytes"
+	"testing"
+
+	"ljos.app/interpreter/ast"
+	"ljos.app/interpreter/lexer"
+	"ljos.app/interpreter/parser"
+)
+
+func TestDump(t *testing.T) {
+	input := `let x = -1 + 2.5; // note
+if (x) { {"a": [true]} }`
+	program := parser.New(lexer.New(input)).ParseProgram()
+
+	var out bytes.Buffer
+	if err := ast.Dump(&out, program); err != nil {
+		t.Fatalf("Dump returned error: %v", err)
+	}
+
+	expected := `Program 1:1
+  Statements[0]: LetStatement 1:1
+    Name: Identifier 1:5 Value="x"
+    Value: InfixExpression 1:9 Operator="+"
+      Left: PrefixExpression 1:9 Operator="-"
+        Right: IntegerLiteral 1:10 Value=1
+      Right: FloatLiteral 1:14 Value=2.5
+  Statements[1]: IfStatement 2:1
+    Condition: Identifier 2:5 Value="x"
+    Value: BlockStatement 2:8
+      Statements[0]: ExpressionStatement 2:10
+        Expression: HashLiteral 2:10
+          Pairs[0]: HashPair
+            Key: StringLiteral 2:11 Value="a"
+            Value: ArrayLiteral 2:16
+              Elements[0]: Boolean 2:17 Value=true
+  Comments[0]: Comment 1:19 Text=" note"
+`
+	if out.String() != expected {
+		t.Errorf("wrong dump. expected=\n%s\ngot=\n%s", expected, out.String())
+	}
+}
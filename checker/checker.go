@@ -0,0 +1,162 @@
+// Package checker finds mistakes in a program without running it.
+package checker
+
+import (
+	"ljos.app/interpreter/ast"
+	"ljos.app/interpreter/diag"
+	"ljos.app/interpreter/token"
+)
+
+// scope holds the names bound by a program, function or for loop. Blocks of
+// if and while share the scope they appear in, as they do when evaluated.
+type scope struct {
+	names map[string]bool
+	outer *scope
+}
+
+func newScope(outer *scope) *scope {
+	return &scope{names: map[string]bool{}, outer: outer}
+}
+
+func (s *scope) defined(name string) bool {
+	for ; s != nil; s = s.outer {
+		if s.names[name] {
+			return true
+		}
+	}
+	return false
+}
+
+type checker struct {
+	diagnostics []diag.Diagnostic
+}
+
+// Check reports the identifiers in program that are not bound by a let, a
+// function parameter or a for loop, and are not among predeclared.
+//
+// A let binds its name in the whole enclosing scope, including the part
+// before it, since a function body referring to a later let is only run
+// once that let has been evaluated.
+func Check(program *ast.Program, predeclared []string) []diag.Diagnostic {
+	c := &checker{}
+	global := newScope(nil)
+	for _, name := range predeclared {
+		global.names[name] = true
+	}
+	s := newScope(global)
+	declareLets(s, program.Statements)
+	for _, stmt := range program.Statements {
+		c.check(s, stmt)
+	}
+	return c.diagnostics
+}
+
+// declareLets binds the names of the lets among stmts, including those in
+// nested if and while blocks but not in functions or for loops.
+func declareLets(s *scope, stmts []ast.Statement) {
+	for _, stmt := range stmts {
+		switch stmt := stmt.(type) {
+		case *ast.LetStatement:
+			s.names[stmt.Name.Value] = true
+		case *ast.IfStatement:
+			declareIfLets(s, stmt)
+		case *ast.WhileStatement:
+			declareLets(s, stmt.Body.Statements)
+		}
+	}
+}
+
+func declareIfLets(s *scope, stmt *ast.IfStatement) {
+	declareLets(s, stmt.Value.Statements)
+	if stmt.ElseIf != nil {
+		declareIfLets(s, stmt.ElseIf)
+	}
+	if stmt.ElseValue != nil {
+		declareLets(s, stmt.ElseValue.Statements)
+	}
+}
+
+func (c *checker) check(s *scope, node ast.Node) {
+	switch node := node.(type) {
+	case *ast.LetStatement:
+		c.check(s, node.Value)
+	case *ast.ReturnStatement:
+		c.check(s, node.Value)
+	case *ast.ExpressionStatement:
+		c.check(s, node.Expression)
+	case *ast.BlockStatement:
+		for _, stmt := range node.Statements {
+			c.check(s, stmt)
+		}
+	case *ast.IfStatement:
+		// an if used as an expression is not seen by declareLets
+		declareIfLets(s, node)
+		c.check(s, node.Condition)
+		c.check(s, node.Value)
+		if node.ElseIf != nil {
+			c.check(s, node.ElseIf)
+		}
+		if node.ElseValue != nil {
+			c.check(s, node.ElseValue)
+		}
+	case *ast.WhileStatement:
+		declareLets(s, node.Body.Statements)
+		c.check(s, node.Condition)
+		c.check(s, node.Body)
+	case *ast.ForStatement:
+		c.check(s, node.Iterable)
+		body := newScope(s)
+		body.names[node.Variable.Value] = true
+		declareLets(body, node.Body.Statements)
+		c.check(body, node.Body)
+
+	case *ast.Identifier:
+		if !s.defined(node.Value) {
+			c.diagnostics = append(c.diagnostics, diag.Errorf(diag.UndefinedName,
+				token.Span{Start: node.Pos(), End: node.End()}, "undefined: %s", node.Value))
+		}
+	case *ast.PrefixExpression:
+		c.check(s, node.Right)
+	case *ast.InfixExpression:
+		c.check(s, node.Left)
+		c.check(s, node.Right)
+	case *ast.AssignExpression:
+		c.check(s, node.Target)
+		c.check(s, node.Value)
+	case *ast.FunctionLiteral:
+		body := newScope(s)
+		for _, param := range node.Parameters {
+			body.names[param.Value] = true
+		}
+		declareLets(body, node.Body.Statements)
+		c.check(body, node.Body)
+	case *ast.CallExpression:
+		c.check(s, node.Function)
+		for _, arg := range node.Arguments {
+			c.check(s, arg)
+		}
+	case *ast.MemberExpression:
+		// the property is looked up on the object, not in scope
+		c.check(s, node.Object)
+	case *ast.ArrayLiteral:
+		for _, el := range node.Elements {
+			c.check(s, el)
+		}
+	case *ast.HashLiteral:
+		for _, pair := range node.Pairs {
+			c.check(s, pair.Key)
+			c.check(s, pair.Value)
+		}
+	case *ast.IndexExpression:
+		c.check(s, node.Left)
+		c.check(s, node.Index)
+	case *ast.SliceExpression:
+		c.check(s, node.Left)
+		if node.Low != nil {
+			c.check(s, node.Low)
+		}
+		if node.High != nil {
+			c.check(s, node.High)
+		}
+	}
+}
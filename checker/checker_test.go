@@ -0,0 +1,66 @@
+package checker
+
+import (
+	"testing"
+
+	"ljos.app/interpreter/lexer"
+	"ljos.app/interpreter/parser"
+)
+
+func TestCheck(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{"let x = 1; x + 1", nil},
+		{"puts(x)", []string{"1:6: error[C0001]: undefined: x"}},
+		{"let f = fn(a) { a + b }", []string{"1:21: error[C0001]: undefined: b"}},
+		{"let fib = fn(n) { if (n < 2) { n } else { fib(n - 1) + fib(n - 2) } }", nil},
+		{"let f = fn() { g() }; let g = fn() { 1 };", nil},
+		{"if (true) { let y = 1 } y", nil},
+		{"while (false) { let y = 1 } y", nil},
+		{"let a = if (true) { let y = 1; y } else { 0 }; puts(a)", nil},
+		{"puts(if (true) { let y = 2; y } else { 0 })", nil},
+		{"let f = fn() { if (true) { if (true) { let y = 3 } } y }", nil},
+		{"puts(if (true) { 1 } else if (false) { let z = 2; z } else { let w = 3; w })", nil},
+		{"for (x in [1]) { let y = x } x + y", []string{
+			"1:30: error[C0001]: undefined: x",
+			"1:34: error[C0001]: undefined: y",
+		}},
+		{"let f = fn(a) { let b = a; b }; a + b", []string{
+			"1:33: error[C0001]: undefined: a",
+			"1:37: error[C0001]: undefined: b",
+		}},
+		{"let m = {}; m.missing = 1; m.len()", nil},
+		{`let m = {"k": v}; m[k]`, []string{
+			"1:15: error[C0001]: undefined: v",
+			"1:21: error[C0001]: undefined: k",
+		}},
+		{"y = 1", []string{"1:1: error[C0001]: undefined: y"}},
+		{"let xs = [1]; xs[i:j]", []string{
+			"1:18: error[C0001]: undefined: i",
+			"1:20: error[C0001]: undefined: j",
+		}},
+		{"let g = (a) => a * c", []string{"1:20: error[C0001]: undefined: c"}},
+		{"-z; !w", []string{"1:2: error[C0001]: undefined: z", "1:6: error[C0001]: undefined: w"}},
+	}
+
+	for _, tt := range tests {
+		p := parser.New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		if len(p.Errors()) != 0 {
+			t.Fatalf("input %s: parser errors: %v", tt.input, p.Errors())
+		}
+
+		diagnostics := Check(program, []string{"puts"})
+		if len(diagnostics) != len(tt.expected) {
+			t.Errorf("input %s: expected %d diagnostics, got %v", tt.input, len(tt.expected), diagnostics)
+			continue
+		}
+		for i, d := range diagnostics {
+			if d.Error() != tt.expected[i] {
+				t.Errorf("input %s: wrong diagnostic. expected=%q, got=%q", tt.input, tt.expected[i], d.Error())
+			}
+		}
+	}
+}
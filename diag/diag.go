@@ -0,0 +1,108 @@
+// Package diag defines the diagnostics reported by the lexer, parser and
+// evaluator, and renders them for terminals or as JSON for editors.
+package diag
+
+import (
+	"fmt"
+
+	"ljos.app/interpreter/token"
+)
+
+type Severity int
+
+const (
+	Error Severity = iota
+	Warning
+	Hint
+)
+
+func (s Severity) String() string {
+	switch s {
+	case Error:
+		return "error"
+	case Warning:
+		return "warning"
+	case Hint:
+		return "hint"
+	}
+	return fmt.Sprintf("severity(%d)", int(s))
+}
+
+func (s Severity) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// Code identifies the kind of a diagnostic independently of its message, so
+// that tools can match on it.
+type Code string
+
+const (
+	// lexer
+	IllegalCharacter    Code = "L0001"
+	UnterminatedString  Code = "L0002"
+	InvalidEscape       Code = "L0003"
+	UnterminatedComment Code = "L0004"
+	MalformedNumber     Code = "L0005"
+	InvalidUTF8         Code = "L0006"
+	ReadFailed          Code = "L0007"
+
+	// parser
+	UnexpectedToken    Code = "P0001"
+	ExpectedExpression Code = "P0002"
+	MissingValue       Code = "P0003"
+	InvalidNumber      Code = "P0004"
+	InvalidParameter   Code = "P0005"
+	UnclosedBlock      Code = "P0006"
+	TooManyErrors      Code = "P0007"
+	InvalidAssignment  Code = "P0008"
+	OutsideLoop        Code = "P0009"
+
+	// checker
+	UndefinedName Code = "C0001"
+
+	// evaluator
+	RuntimeError Code = "R0001"
+)
+
+// Fix is a suggested edit replacing the source covered by Span with
+// Replacement. An empty span is an insertion.
+type Fix struct {
+	Message     string     `json:"message"`
+	Span        token.Span `json:"span"`
+	Replacement string     `json:"replacement"`
+}
+
+type Diagnostic struct {
+	Severity Severity   `json:"severity"`
+	Code     Code       `json:"code"`
+	Span     token.Span `json:"span"`
+	Message  string     `json:"message"`
+	Notes    []string   `json:"notes,omitempty"`
+	Fixes    []Fix      `json:"fixes,omitempty"`
+}
+
+// Errorf creates an error diagnostic covering span.
+func Errorf(code Code, span token.Span, format string, args ...any) Diagnostic {
+	return Diagnostic{
+		Severity: Error,
+		Code:     code,
+		Span:     span,
+		Message:  fmt.Sprintf(format, args...),
+	}
+}
+
+// Error formats the diagnostic on a single line, e.g.
+// "main.hua:3:7: error[P0001]: expected next token to be =, got INT instead".
+func (d Diagnostic) Error() string {
+	return fmt.Sprintf("%s: %s[%s]: %s", d.Span.Start, d.Severity, d.Code, d.Message)
+}
+
+// HasErrors reports whether any of the diagnostics is an error.
+func HasErrors(ds []Diagnostic) bool {
+	for _, d := range ds {
+		if d.Severity == Error {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,94 @@
+package diag
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"ljos.app/interpreter/token"
+)
+
+func span(line, col, offset, length int) token.Span {
+	return token.Span{
+		Start: token.Position{File: "main.hua", Line: line, Column: col, Offset: offset},
+		End:   token.Position{File: "main.hua", Line: line, Column: col + length, Offset: offset + length},
+	}
+}
+
+func TestRender(t *testing.T) {
+	source := "let x = 5;\n\tlet total = x +* 2;\nlet s = \"größe\" + y;\n"
+	tests := []struct {
+		diagnostic Diagnostic
+		expected   string
+	}{
+		{
+			Errorf(UnexpectedToken, span(1, 7, 6, 1), "expected next token to be %s", "IDENTIFIER"),
+			"main.hua:1:7: error[P0001]: expected next token to be IDENTIFIER\n" +
+				"  1 | let x = 5;\n" +
+				"    |       ^\n",
+		},
+		{
+			Diagnostic{
+				Severity: Error,
+				Code:     ExpectedExpression,
+				Span:     span(2, 17, 27, 1),
+				Message:  "expected an expression, got * instead",
+				Notes:    []string{"operators need an operand on both sides"},
+				Fixes:    []Fix{{Message: "remove \"*\"", Span: span(2, 17, 27, 1)}},
+			},
+			"main.hua:2:17: error[P0002]: expected an expression, got * instead\n" +
+				"  2 | \tlet total = x +* 2;\n" +
+				"    | \t               ^\n" +
+				"  = note: operators need an operand on both sides\n" +
+				"  = help: remove \"*\"\n",
+		},
+		{
+			Diagnostic{Severity: Warning, Code: RuntimeError, Span: span(3, 9, 40, 9), Message: "long string"},
+			"main.hua:3:9: warning[R0001]: long string\n" +
+				"  3 | let s = \"größe\" + y;\n" +
+				"    |         ^~~~~~~\n",
+		},
+	}
+
+	r := NewRenderer(source)
+	for i, tt := range tests {
+		var out bytes.Buffer
+		if err := r.Render(&out, tt.diagnostic); err != nil {
+			t.Fatalf("tests[%d] - Render returned error: %v", i, err)
+		}
+		if out.String() != tt.expected {
+			t.Errorf("tests[%d] - wrong rendering. expected=\n%s\ngot=\n%s", i, tt.expected, out.String())
+		}
+	}
+}
+
+func TestRenderWithoutSource(t *testing.T) {
+	d := Errorf(IllegalCharacter, span(1, 1, 0, 1), "illegal character %q", "@")
+	expected := "main.hua:1:1: error[L0001]: illegal character \"@\"\n"
+	if got := NewRenderer("").Format(d); got != expected {
+		t.Errorf("wrong rendering. expected=%q, got=%q", expected, got)
+	}
+}
+
+func TestWriteJSON(t *testing.T) {
+	d := Errorf(IllegalCharacter, span(1, 1, 0, 1), "illegal character %q", "@")
+
+	var out bytes.Buffer
+	if err := WriteJSON(&out, []Diagnostic{d}); err != nil {
+		t.Fatalf("WriteJSON returned error: %v", err)
+	}
+	var decoded []map[string]any
+	if err := json.Unmarshal(out.Bytes(), &decoded); err != nil {
+		t.Fatalf("output is not valid JSON: %v\n%s", err, out.String())
+	}
+	if len(decoded) != 1 {
+		t.Fatalf("expected 1 diagnostic, got %d", len(decoded))
+	}
+	if decoded[0]["severity"] != "error" || decoded[0]["code"] != "L0001" {
+		t.Errorf("wrong severity or code, got %v", decoded[0])
+	}
+	start := decoded[0]["span"].(map[string]any)["start"].(map[string]any)
+	if start["line"] != 1.0 || start["file"] != "main.hua" {
+		t.Errorf("wrong span start, got %v", start)
+	}
+}
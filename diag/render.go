@@ -0,0 +1,124 @@
+package diag
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+
+	"ljos.app/interpreter/token"
+)
+
+// Renderer prints diagnostics for a terminal, quoting the offending source
+// line and underlining the span of the diagnostic:
+//
+//	main.hua:1:7: error[P0001]: expected next token to be =, got INT instead
+//	  1 | let x 5;
+//	    |       ^
+type Renderer struct {
+	source     string
+	lineStarts []int
+}
+
+// NewRenderer creates a renderer for diagnostics in source. With an empty
+// source only the header line of each diagnostic is printed.
+func NewRenderer(source string) *Renderer {
+	r := &Renderer{source: source, lineStarts: []int{0}}
+	for i := 0; i < len(source); i++ {
+		if source[i] == '\n' {
+			r.lineStarts = append(r.lineStarts, i+1)
+		}
+	}
+	return r
+}
+
+func (r *Renderer) Render(w io.Writer, ds ...Diagnostic) error {
+	for _, d := range ds {
+		if _, err := io.WriteString(w, r.Format(d)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Format returns the rendering of a single diagnostic, ending in a newline.
+func (r *Renderer) Format(d Diagnostic) string {
+	var out strings.Builder
+	out.WriteString(d.Error())
+	out.WriteString("\n")
+
+	line, ok := r.line(d.Span.Start.Line)
+	start := d.Span.Start.Offset - r.lineStart(d.Span.Start.Line)
+	if ok && start >= 0 && start <= len(line) {
+		number := fmt.Sprintf("%d", d.Span.Start.Line)
+		gutter := strings.Repeat(" ", len(number))
+
+		fmt.Fprintf(&out, "  %s | %s\n", number, line)
+		fmt.Fprintf(&out, "  %s | %s%s\n", gutter, indent(line[:start]), underline(line, start, d.Span))
+	}
+
+	for _, note := range d.Notes {
+		fmt.Fprintf(&out, "  = note: %s\n", note)
+	}
+	for _, fix := range d.Fixes {
+		fmt.Fprintf(&out, "  = help: %s\n", fix.Message)
+	}
+	return out.String()
+}
+
+func (r *Renderer) line(n int) (string, bool) {
+	if r.source == "" || n < 1 || n > len(r.lineStarts) {
+		return "", false
+	}
+	end := len(r.source)
+	if n < len(r.lineStarts) {
+		end = r.lineStarts[n] - 1
+	}
+	return strings.TrimSuffix(r.source[r.lineStarts[n-1]:end], "\r"), true
+}
+
+func (r *Renderer) lineStart(n int) int {
+	if n < 1 || n > len(r.lineStarts) {
+		return 0
+	}
+	return r.lineStarts[n-1]
+}
+
+// indent replaces everything but tabs in prefix with spaces so that the
+// underline lines up with the quoted source line.
+func indent(prefix string) string {
+	var out strings.Builder
+	for _, ch := range prefix {
+		if ch == '\t' {
+			out.WriteRune('\t')
+		} else {
+			out.WriteRune(' ')
+		}
+	}
+	return out.String()
+}
+
+// underline marks the span with a caret followed by tildes, stopping at the
+// end of the line for spans covering several lines.
+func underline(line string, start int, span token.Span) string {
+	end := len(line)
+	if span.End.Line == span.Start.Line && span.End.Offset >= span.Start.Offset {
+		end = min(start+span.End.Offset-span.Start.Offset, len(line))
+	}
+	width := utf8.RuneCountInString(line[start:end])
+	if width <= 1 {
+		return "^"
+	}
+	return "^" + strings.Repeat("~", width-1)
+}
+
+// WriteJSON writes the diagnostics as a JSON array.
+func WriteJSON(w io.Writer, ds []Diagnostic) error {
+	if ds == nil {
+		ds = []Diagnostic{}
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(ds)
+}
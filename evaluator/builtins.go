@@ -0,0 +1,290 @@
+package evaluator
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+
+	"ljos.app/interpreter/object"
+)
+
+// method is a builtin method, called with the receiver it was looked up on.
+type method func(receiver object.Object, args ...object.Object) object.Object
+
+// methods holds the builtin methods of each type. It is filled in init since
+// map and filter call back into the evaluator.
+var methods map[object.ObjectType]map[string]method
+
+func init() {
+	methods = map[object.ObjectType]map[string]method{
+		object.STRING_OBJ: {
+			"len":      stringLen,
+			"upper":    stringUpper,
+			"lower":    stringLower,
+			"trim":     stringTrim,
+			"split":    stringSplit,
+			"contains": stringContains,
+		},
+		object.ARRAY_OBJ: {
+			"len":    arrayLen,
+			"push":   arrayPush,
+			"pop":    arrayPop,
+			"first":  arrayFirst,
+			"last":   arrayLast,
+			"join":   arrayJoin,
+			"map":    arrayMap,
+			"filter": arrayFilter,
+		},
+		object.HASH_OBJ: {
+			"len":    hashLen,
+			"keys":   hashKeys,
+			"values": hashValues,
+			"has":    hashHas,
+		},
+	}
+}
+
+// NewGlobalEnvironment creates the environment programs are evaluated in,
+// with the builtin functions bound. puts writes to out.
+func NewGlobalEnvironment(out io.Writer) *object.Environment {
+	env := object.NewEnvironment()
+	env.Set("puts", &object.Builtin{
+		Name: "puts",
+		Fn: func(args ...object.Object) object.Object {
+			for _, arg := range args {
+				fmt.Fprintln(out, arg.Inspect())
+			}
+			return NULL
+		},
+	})
+	return env
+}
+
+// lookupMethod returns the named method of obj bound to obj, or nil if obj
+// has no such method.
+func lookupMethod(obj object.Object, name string) *object.Builtin {
+	m, ok := methods[obj.Type()][name]
+	if !ok {
+		return nil
+	}
+	return &object.Builtin{
+		Name: name,
+		Fn: func(args ...object.Object) object.Object {
+			return m(obj, args...)
+		},
+	}
+}
+
+func checkArgCount(args []object.Object, expected int) *object.Error {
+	if len(args) != expected {
+		return newError("wrong number of arguments: expected %d, got %d", expected, len(args))
+	}
+	return nil
+}
+
+func stringArg(name string, args []object.Object) (string, *object.Error) {
+	if err := checkArgCount(args, 1); err != nil {
+		return "", err
+	}
+	str, ok := args[0].(*object.String)
+	if !ok {
+		return "", newError("argument to %s must be STRING, got %s", name, args[0].Type())
+	}
+	return str.Value, nil
+}
+
+func stringLen(receiver object.Object, args ...object.Object) object.Object {
+	if err := checkArgCount(args, 0); err != nil {
+		return err
+	}
+	return &object.Integer{Value: int64(utf8.RuneCountInString(receiver.(*object.String).Value))}
+}
+
+func stringUpper(receiver object.Object, args ...object.Object) object.Object {
+	if err := checkArgCount(args, 0); err != nil {
+		return err
+	}
+	return &object.String{Value: strings.ToUpper(receiver.(*object.String).Value)}
+}
+
+func stringLower(receiver object.Object, args ...object.Object) object.Object {
+	if err := checkArgCount(args, 0); err != nil {
+		return err
+	}
+	return &object.String{Value: strings.ToLower(receiver.(*object.String).Value)}
+}
+
+func stringTrim(receiver object.Object, args ...object.Object) object.Object {
+	if err := checkArgCount(args, 0); err != nil {
+		return err
+	}
+	return &object.String{Value: strings.TrimSpace(receiver.(*object.String).Value)}
+}
+
+func stringSplit(receiver object.Object, args ...object.Object) object.Object {
+	sep, err := stringArg("split", args)
+	if err != nil {
+		return err
+	}
+	parts := strings.Split(receiver.(*object.String).Value, sep)
+	elements := make([]object.Object, len(parts))
+	for i, part := range parts {
+		elements[i] = &object.String{Value: part}
+	}
+	return &object.Array{Elements: elements}
+}
+
+func stringContains(receiver object.Object, args ...object.Object) object.Object {
+	sub, err := stringArg("contains", args)
+	if err != nil {
+		return err
+	}
+	return nativeBoolToBooleanObject(strings.Contains(receiver.(*object.String).Value, sub))
+}
+
+func arrayLen(receiver object.Object, args ...object.Object) object.Object {
+	if err := checkArgCount(args, 0); err != nil {
+		return err
+	}
+	return &object.Integer{Value: int64(len(receiver.(*object.Array).Elements))}
+}
+
+// arrayPush appends to the array in place and returns it, so pushes can be
+// chained.
+func arrayPush(receiver object.Object, args ...object.Object) object.Object {
+	if err := checkArgCount(args, 1); err != nil {
+		return err
+	}
+	array := receiver.(*object.Array)
+	array.Elements = append(array.Elements, args[0])
+	return array
+}
+
+// arrayPop removes and returns the last element, or null if the array is
+// empty.
+func arrayPop(receiver object.Object, args ...object.Object) object.Object {
+	if err := checkArgCount(args, 0); err != nil {
+		return err
+	}
+	array := receiver.(*object.Array)
+	if len(array.Elements) == 0 {
+		return NULL
+	}
+	last := array.Elements[len(array.Elements)-1]
+	array.Elements = array.Elements[:len(array.Elements)-1]
+	return last
+}
+
+func arrayFirst(receiver object.Object, args ...object.Object) object.Object {
+	if err := checkArgCount(args, 0); err != nil {
+		return err
+	}
+	array := receiver.(*object.Array)
+	if len(array.Elements) == 0 {
+		return NULL
+	}
+	return array.Elements[0]
+}
+
+func arrayLast(receiver object.Object, args ...object.Object) object.Object {
+	if err := checkArgCount(args, 0); err != nil {
+		return err
+	}
+	array := receiver.(*object.Array)
+	if len(array.Elements) == 0 {
+		return NULL
+	}
+	return array.Elements[len(array.Elements)-1]
+}
+
+func arrayJoin(receiver object.Object, args ...object.Object) object.Object {
+	sep, err := stringArg("join", args)
+	if err != nil {
+		return err
+	}
+	parts := []string{}
+	for _, el := range receiver.(*object.Array).Elements {
+		parts = append(parts, el.Inspect())
+	}
+	return &object.String{Value: strings.Join(parts, sep)}
+}
+
+// arrayMap returns a new array with fn applied to every element.
+func arrayMap(receiver object.Object, args ...object.Object) object.Object {
+	if err := checkArgCount(args, 1); err != nil {
+		return err
+	}
+	elements := []object.Object{}
+	for _, el := range receiver.(*object.Array).Elements {
+		result := applyFunction(args[0], []object.Object{el})
+		if isError(result) {
+			return result
+		}
+		elements = append(elements, result)
+	}
+	return &object.Array{Elements: elements}
+}
+
+// arrayFilter returns a new array with the elements fn is truthy for.
+func arrayFilter(receiver object.Object, args ...object.Object) object.Object {
+	if err := checkArgCount(args, 1); err != nil {
+		return err
+	}
+	elements := []object.Object{}
+	for _, el := range receiver.(*object.Array).Elements {
+		result := applyFunction(args[0], []object.Object{el})
+		if isError(result) {
+			return result
+		}
+		if isTruthy(result) {
+			elements = append(elements, el)
+		}
+	}
+	return &object.Array{Elements: elements}
+}
+
+func hashLen(receiver object.Object, args ...object.Object) object.Object {
+	if err := checkArgCount(args, 0); err != nil {
+		return err
+	}
+	return &object.Integer{Value: int64(len(receiver.(*object.Hash).Keys))}
+}
+
+// hashKeys returns the keys in insertion order.
+func hashKeys(receiver object.Object, args ...object.Object) object.Object {
+	if err := checkArgCount(args, 0); err != nil {
+		return err
+	}
+	hash := receiver.(*object.Hash)
+	keys := []object.Object{}
+	for _, key := range hash.Keys {
+		keys = append(keys, hash.Pairs[key].Key)
+	}
+	return &object.Array{Elements: keys}
+}
+
+// hashValues returns the values in the insertion order of their keys.
+func hashValues(receiver object.Object, args ...object.Object) object.Object {
+	if err := checkArgCount(args, 0); err != nil {
+		return err
+	}
+	hash := receiver.(*object.Hash)
+	values := []object.Object{}
+	for _, key := range hash.Keys {
+		values = append(values, hash.Pairs[key].Value)
+	}
+	return &object.Array{Elements: values}
+}
+
+func hashHas(receiver object.Object, args ...object.Object) object.Object {
+	if err := checkArgCount(args, 1); err != nil {
+		return err
+	}
+	key, ok := args[0].(object.Hashable)
+	if !ok {
+		return newError("unusable as hash key: %s", args[0].Type())
+	}
+	_, ok = receiver.(*object.Hash).Get(key)
+	return nativeBoolToBooleanObject(ok)
+}
@@ -0,0 +1,774 @@
+package evaluator
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"ljos.app/interpreter/ast"
+	"ljos.app/interpreter/object"
+	"ljos.app/interpreter/token"
+)
+
+var (
+	NULL  = &object.Null{}
+	TRUE  = &object.Boolean{Value: true}
+	FALSE = &object.Boolean{Value: false}
+
+	BREAK    = &object.Break{}
+	CONTINUE = &object.Continue{}
+)
+
+// MaxCallDepth is the number of nested function calls after which evaluation
+// stops with an error, rather than overflowing the Go stack.
+const MaxCallDepth = 10000
+
+func Eval(node ast.Node, env *object.Environment) object.Object {
+	result := eval(node, env)
+	if err, ok := result.(*object.Error); ok && !err.Span.Start.IsValid() && node != nil {
+		err.Span = token.Span{Start: node.Pos(), End: node.End()}
+	}
+	return result
+}
+
+func eval(node ast.Node, env *object.Environment) object.Object {
+	switch node := node.(type) {
+
+	// statements
+	case *ast.Program:
+		return evalProgram(node, env)
+	case *ast.ExpressionStatement:
+		return Eval(node.Expression, env)
+	case *ast.BlockStatement:
+		return evalBlockStatement(node, env)
+	case *ast.LetStatement:
+		val := Eval(node.Value, env)
+		if stopsEvaluation(val) {
+			return val
+		}
+		env.Set(node.Name.Value, val)
+		return NULL
+	case *ast.ReturnStatement:
+		val := Eval(node.Value, env)
+		if stopsEvaluation(val) {
+			return val
+		}
+		return &object.ReturnValue{Value: val}
+	case *ast.IfStatement:
+		return evalIfStatement(node, env)
+	case *ast.WhileStatement:
+		return evalWhileStatement(node, env)
+	case *ast.ForStatement:
+		return evalForStatement(node, env)
+	case *ast.BreakStatement:
+		return BREAK
+	case *ast.ContinueStatement:
+		return CONTINUE
+
+	// expressions
+	case *ast.IntegerLiteral:
+		return &object.Integer{Value: node.Value}
+	case *ast.FloatLiteral:
+		return &object.Float{Value: node.Value}
+	case *ast.StringLiteral:
+		return &object.String{Value: node.Value}
+	case *ast.Boolean:
+		return nativeBoolToBooleanObject(node.Value)
+	case *ast.Identifier:
+		return evalIdentifier(node, env)
+	case *ast.PrefixExpression:
+		right := Eval(node.Right, env)
+		if stopsEvaluation(right) {
+			return right
+		}
+		return evalPrefixExpression(node.Operator, right)
+	case *ast.InfixExpression:
+		left := Eval(node.Left, env)
+		if stopsEvaluation(left) {
+			return left
+		}
+		if node.Operator == "&&" || node.Operator == "||" {
+			return evalLogicalExpression(node, left, env)
+		}
+		right := Eval(node.Right, env)
+		if stopsEvaluation(right) {
+			return right
+		}
+		return evalInfixExpression(node.Operator, left, right)
+	case *ast.AssignExpression:
+		return evalAssignExpression(node, env)
+	case *ast.FunctionLiteral:
+		return &object.Function{Parameters: node.Parameters, Body: node.Body, Env: env}
+	case *ast.CallExpression:
+		function := Eval(node.Function, env)
+		if stopsEvaluation(function) {
+			return function
+		}
+		args := evalExpressions(node.Arguments, env)
+		if len(args) == 1 && stopsEvaluation(args[0]) {
+			return args[0]
+		}
+		return applyFunction(function, args)
+
+	case *ast.ArrayLiteral:
+		elements := evalExpressions(node.Elements, env)
+		if len(elements) == 1 && stopsEvaluation(elements[0]) {
+			return elements[0]
+		}
+		return &object.Array{Elements: elements}
+	case *ast.HashLiteral:
+		return evalHashLiteral(node, env)
+	case *ast.IndexExpression:
+		left := Eval(node.Left, env)
+		if stopsEvaluation(left) {
+			return left
+		}
+		index := Eval(node.Index, env)
+		if stopsEvaluation(index) {
+			return index
+		}
+		return evalIndexExpression(left, index)
+	case *ast.SliceExpression:
+		return evalSliceExpression(node, env)
+	case *ast.MemberExpression:
+		obj := Eval(node.Object, env)
+		if stopsEvaluation(obj) {
+			return obj
+		}
+		return evalMemberExpression(obj, node.Property.Value)
+
+	case *ast.BadStatement, *ast.BadExpression:
+		return newError("cannot evaluate code with syntax errors")
+	}
+
+	return newError("cannot evaluate %T", node)
+}
+
+func evalProgram(program *ast.Program, env *object.Environment) object.Object {
+	var result object.Object = NULL
+	for _, statement := range program.Statements {
+		result = Eval(statement, env)
+
+		switch result := result.(type) {
+		case *object.ReturnValue:
+			return result.Value
+		case *object.Error:
+			return result
+		}
+	}
+	return result
+}
+
+// evalBlockStatement does not unwrap return values, so that a return inside
+// nested blocks stops evaluation all the way up to the enclosing function.
+// Break and continue likewise stop it up to the enclosing loop.
+func evalBlockStatement(block *ast.BlockStatement, env *object.Environment) object.Object {
+	var result object.Object = NULL
+	for _, statement := range block.Statements {
+		result = Eval(statement, env)
+
+		if result != nil {
+			switch result.Type() {
+			case object.RETURN_VALUE_OBJ, object.ERROR_OBJ, object.BREAK_OBJ, object.CONTINUE_OBJ:
+				return result
+			}
+		}
+	}
+	return result
+}
+
+func evalIfStatement(is *ast.IfStatement, env *object.Environment) object.Object {
+	condition := Eval(is.Condition, env)
+	if stopsEvaluation(condition) {
+		return condition
+	}
+
+	if isTruthy(condition) {
+		return Eval(is.Value, env)
+	} else if is.ElseIf != nil {
+		return evalIfStatement(is.ElseIf, env)
+	} else if is.ElseValue != nil {
+		return Eval(is.ElseValue, env)
+	}
+	return NULL
+}
+
+func evalWhileStatement(ws *ast.WhileStatement, env *object.Environment) object.Object {
+	for {
+		condition := Eval(ws.Condition, env)
+		if stopsEvaluation(condition) {
+			return condition
+		}
+		if !isTruthy(condition) {
+			return NULL
+		}
+		if result, done := evalLoopBody(ws.Body, env); done {
+			return result
+		}
+	}
+}
+
+// evalForStatement iterates over the elements of an array, the keys of a
+// hash or the characters of a string, as they were when the loop started.
+// Each iteration gets its own scope holding the loop variable, so closures
+// created in the body capture that iteration's value.
+func evalForStatement(fs *ast.ForStatement, env *object.Environment) object.Object {
+	iterable := Eval(fs.Iterable, env)
+	if stopsEvaluation(iterable) {
+		return iterable
+	}
+
+	var items []object.Object
+	switch iterable := iterable.(type) {
+	case *object.Array:
+		items = append(items, iterable.Elements...)
+	case *object.Hash:
+		for _, key := range iterable.Keys {
+			items = append(items, iterable.Pairs[key].Key)
+		}
+	case *object.String:
+		for _, ch := range iterable.Value {
+			items = append(items, &object.String{Value: string(ch)})
+		}
+	default:
+		return newError("cannot iterate over %s", iterable.Type())
+	}
+
+	for _, item := range items {
+		iterationEnv := object.NewEnclosedEnvironment(env)
+		iterationEnv.Set(fs.Variable.Value, item)
+		if result, done := evalLoopBody(fs.Body, iterationEnv); done {
+			return result
+		}
+	}
+	return NULL
+}
+
+// evalLoopBody runs one iteration of a loop. It reports done when the loop
+// has to stop, with the result being null after a break, or the return
+// value or error to pass on.
+func evalLoopBody(body *ast.BlockStatement, env *object.Environment) (object.Object, bool) {
+	switch result := Eval(body, env).(type) {
+	case *object.Break:
+		return NULL, true
+	case *object.ReturnValue, *object.Error:
+		return result, true
+	}
+	return nil, false
+}
+
+func evalAssignExpression(node *ast.AssignExpression, env *object.Environment) object.Object {
+	switch target := node.Target.(type) {
+	case *ast.IndexExpression:
+		return evalIndexAssignment(node, target, env)
+	case *ast.MemberExpression:
+		return evalMemberAssignment(node, target, env)
+	}
+
+	name := node.Target.(*ast.Identifier).Value
+	current, ok := env.Get(name)
+	if !ok {
+		return newError("cannot assign to undeclared variable %s, declare it with let first", name)
+	}
+
+	val := evalAssignedValue(node, current, env)
+	if stopsEvaluation(val) {
+		return val
+	}
+	env.Assign(name, val)
+	return val
+}
+
+// evalIndexAssignment stores into an array element or hash entry, as in
+// `xs[0] = 1` or `m["k"] += 1`.
+func evalIndexAssignment(node *ast.AssignExpression, target *ast.IndexExpression, env *object.Environment) object.Object {
+	left := Eval(target.Left, env)
+	if stopsEvaluation(left) {
+		return left
+	}
+	index := Eval(target.Index, env)
+	if stopsEvaluation(index) {
+		return index
+	}
+
+	switch left := left.(type) {
+	case *object.Array:
+		i, ok := index.(*object.Integer)
+		if !ok {
+			return newError("array index must be INTEGER, got %s", index.Type())
+		}
+		if i.Value < 0 || i.Value >= int64(len(left.Elements)) {
+			return newError("index out of range: %d with length %d", i.Value, len(left.Elements))
+		}
+		val := evalAssignedValue(node, left.Elements[i.Value], env)
+		if stopsEvaluation(val) {
+			return val
+		}
+		left.Elements[i.Value] = val
+		return val
+	case *object.Hash:
+		key, ok := index.(object.Hashable)
+		if !ok {
+			return newError("unusable as hash key: %s", index.Type())
+		}
+		current, ok := left.Get(key)
+		if !ok {
+			current = NULL
+		}
+		val := evalAssignedValue(node, current, env)
+		if stopsEvaluation(val) {
+			return val
+		}
+		left.Set(key, val)
+		return val
+	}
+	return newError("index assignment not supported: %s", left.Type())
+}
+
+// evalMemberAssignment stores a hash field, `m.name = 1` being the same as
+// `m["name"] = 1`.
+func evalMemberAssignment(node *ast.AssignExpression, target *ast.MemberExpression, env *object.Environment) object.Object {
+	obj := Eval(target.Object, env)
+	if stopsEvaluation(obj) {
+		return obj
+	}
+	hash, ok := obj.(*object.Hash)
+	if !ok {
+		return newError("member assignment not supported: %s", obj.Type())
+	}
+
+	key := &object.String{Value: target.Property.Value}
+	current, ok := hash.Get(key)
+	if !ok {
+		current = NULL
+	}
+	val := evalAssignedValue(node, current, env)
+	if stopsEvaluation(val) {
+		return val
+	}
+	hash.Set(key, val)
+	return val
+}
+
+// evalAssignedValue evaluates the right side of an assignment, combining it
+// with the current value for compound operators.
+func evalAssignedValue(node *ast.AssignExpression, current object.Object, env *object.Environment) object.Object {
+	val := Eval(node.Value, env)
+	if stopsEvaluation(val) || node.Operator == "=" {
+		return val
+	}
+	// x += y is x = x + y
+	operator := strings.TrimSuffix(node.Operator, "=")
+	return evalInfixExpression(operator, current, val)
+}
+
+// evalLogicalExpression only evaluates the right side of && and || when the
+// left side does not decide the result.
+func evalLogicalExpression(node *ast.InfixExpression, left object.Object, env *object.Environment) object.Object {
+	if isTruthy(left) == (node.Operator == "||") {
+		return nativeBoolToBooleanObject(isTruthy(left))
+	}
+	right := Eval(node.Right, env)
+	if stopsEvaluation(right) {
+		return right
+	}
+	return nativeBoolToBooleanObject(isTruthy(right))
+}
+
+func evalIdentifier(node *ast.Identifier, env *object.Environment) object.Object {
+	val, ok := env.Get(node.Value)
+	if !ok {
+		return newError("identifier not found: %s", node.Value)
+	}
+	return val
+}
+
+func evalPrefixExpression(operator string, right object.Object) object.Object {
+	switch operator {
+	case "!":
+		return nativeBoolToBooleanObject(!isTruthy(right))
+	case "-":
+		switch right := right.(type) {
+		case *object.Integer:
+			return &object.Integer{Value: -right.Value}
+		case *object.Float:
+			return &object.Float{Value: -right.Value}
+		}
+		return newError("unknown operator: -%s", right.Type())
+	}
+	return newError("unknown operator: %s%s", operator, right.Type())
+}
+
+func evalInfixExpression(operator string, left, right object.Object) object.Object {
+	switch {
+	case left.Type() == object.INTEGER_OBJ && right.Type() == object.INTEGER_OBJ:
+		return evalIntegerInfixExpression(operator, left, right)
+	case isNumber(left) && isNumber(right):
+		return evalFloatInfixExpression(operator, left, right)
+	case left.Type() == object.STRING_OBJ && right.Type() == object.STRING_OBJ:
+		return evalStringInfixExpression(operator, left, right)
+	case left.Type() != right.Type():
+		return newError("type mismatch: %s %s %s", left.Type(), operator, right.Type())
+	case operator == "==":
+		return nativeBoolToBooleanObject(left == right)
+	case operator == "!=":
+		return nativeBoolToBooleanObject(left != right)
+	}
+	return newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
+}
+
+func evalIntegerInfixExpression(operator string, left, right object.Object) object.Object {
+	leftVal := left.(*object.Integer).Value
+	rightVal := right.(*object.Integer).Value
+
+	switch operator {
+	case "+":
+		return &object.Integer{Value: leftVal + rightVal}
+	case "-":
+		return &object.Integer{Value: leftVal - rightVal}
+	case "*":
+		return &object.Integer{Value: leftVal * rightVal}
+	case "/":
+		if rightVal == 0 {
+			return newError("division by zero: %d / %d", leftVal, rightVal)
+		}
+		return &object.Integer{Value: leftVal / rightVal}
+	case "%":
+		if rightVal == 0 {
+			return newError("division by zero: %d %% %d", leftVal, rightVal)
+		}
+		return &object.Integer{Value: leftVal % rightVal}
+	case "**":
+		if rightVal < 0 {
+			return &object.Float{Value: math.Pow(float64(leftVal), float64(rightVal))}
+		}
+		return &object.Integer{Value: integerPower(leftVal, rightVal)}
+	case "&":
+		return &object.Integer{Value: leftVal & rightVal}
+	case "|":
+		return &object.Integer{Value: leftVal | rightVal}
+	case "^":
+		return &object.Integer{Value: leftVal ^ rightVal}
+	case "<<", ">>":
+		if rightVal < 0 {
+			return newError("negative shift count: %d %s %d", leftVal, operator, rightVal)
+		}
+		if operator == "<<" {
+			return &object.Integer{Value: leftVal << rightVal}
+		}
+		return &object.Integer{Value: leftVal >> rightVal}
+	case "<":
+		return nativeBoolToBooleanObject(leftVal < rightVal)
+	case ">":
+		return nativeBoolToBooleanObject(leftVal > rightVal)
+	case "<=":
+		return nativeBoolToBooleanObject(leftVal <= rightVal)
+	case ">=":
+		return nativeBoolToBooleanObject(leftVal >= rightVal)
+	case "==":
+		return nativeBoolToBooleanObject(leftVal == rightVal)
+	case "!=":
+		return nativeBoolToBooleanObject(leftVal != rightVal)
+	}
+	return newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
+}
+
+// evalFloatInfixExpression evaluates arithmetic where at least one side is a
+// float, converting an integer on the other side. Floats follow IEEE 754, so
+// dividing by zero gives an infinity rather than an error.
+func evalFloatInfixExpression(operator string, left, right object.Object) object.Object {
+	leftVal := toFloat(left)
+	rightVal := toFloat(right)
+
+	switch operator {
+	case "+":
+		return &object.Float{Value: leftVal + rightVal}
+	case "-":
+		return &object.Float{Value: leftVal - rightVal}
+	case "*":
+		return &object.Float{Value: leftVal * rightVal}
+	case "/":
+		return &object.Float{Value: leftVal / rightVal}
+	case "%":
+		return &object.Float{Value: math.Mod(leftVal, rightVal)}
+	case "**":
+		return &object.Float{Value: math.Pow(leftVal, rightVal)}
+	case "<":
+		return nativeBoolToBooleanObject(leftVal < rightVal)
+	case ">":
+		return nativeBoolToBooleanObject(leftVal > rightVal)
+	case "<=":
+		return nativeBoolToBooleanObject(leftVal <= rightVal)
+	case ">=":
+		return nativeBoolToBooleanObject(leftVal >= rightVal)
+	case "==":
+		return nativeBoolToBooleanObject(leftVal == rightVal)
+	case "!=":
+		return nativeBoolToBooleanObject(leftVal != rightVal)
+	}
+	return newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
+}
+
+// integerPower computes base ** exp for exp >= 0 by repeated squaring,
+// wrapping around on overflow like the other integer operators.
+func integerPower(base, exp int64) int64 {
+	result := int64(1)
+	for exp > 0 {
+		if exp&1 == 1 {
+			result *= base
+		}
+		base *= base
+		exp >>= 1
+	}
+	return result
+}
+
+func isNumber(obj object.Object) bool {
+	return obj.Type() == object.INTEGER_OBJ || obj.Type() == object.FLOAT_OBJ
+}
+
+func toFloat(obj object.Object) float64 {
+	switch obj := obj.(type) {
+	case *object.Integer:
+		return float64(obj.Value)
+	case *object.Float:
+		return obj.Value
+	}
+	return 0
+}
+
+func evalStringInfixExpression(operator string, left, right object.Object) object.Object {
+	leftVal := left.(*object.String).Value
+	rightVal := right.(*object.String).Value
+
+	switch operator {
+	case "+":
+		return &object.String{Value: leftVal + rightVal}
+	case "<":
+		return nativeBoolToBooleanObject(leftVal < rightVal)
+	case ">":
+		return nativeBoolToBooleanObject(leftVal > rightVal)
+	case "<=":
+		return nativeBoolToBooleanObject(leftVal <= rightVal)
+	case ">=":
+		return nativeBoolToBooleanObject(leftVal >= rightVal)
+	case "==":
+		return nativeBoolToBooleanObject(leftVal == rightVal)
+	case "!=":
+		return nativeBoolToBooleanObject(leftVal != rightVal)
+	}
+	return newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
+}
+
+func evalHashLiteral(node *ast.HashLiteral, env *object.Environment) object.Object {
+	hash := object.NewHash()
+	for _, pair := range node.Pairs {
+		key := Eval(pair.Key, env)
+		if stopsEvaluation(key) {
+			return key
+		}
+		hashKey, ok := key.(object.Hashable)
+		if !ok {
+			return newError("unusable as hash key: %s", key.Type())
+		}
+		value := Eval(pair.Value, env)
+		if stopsEvaluation(value) {
+			return value
+		}
+		hash.Set(hashKey, value)
+	}
+	return hash
+}
+
+// evalIndexExpression looks up an element of an array, string or hash.
+// Indexing past the end of an array or string, or with a missing hash key,
+// gives null.
+func evalIndexExpression(left, index object.Object) object.Object {
+	switch left := left.(type) {
+	case *object.Array:
+		i, ok := index.(*object.Integer)
+		if !ok {
+			return newError("array index must be INTEGER, got %s", index.Type())
+		}
+		if i.Value < 0 || i.Value >= int64(len(left.Elements)) {
+			return NULL
+		}
+		return left.Elements[i.Value]
+	case *object.String:
+		i, ok := index.(*object.Integer)
+		if !ok {
+			return newError("string index must be INTEGER, got %s", index.Type())
+		}
+		runes := []rune(left.Value)
+		if i.Value < 0 || i.Value >= int64(len(runes)) {
+			return NULL
+		}
+		return &object.String{Value: string(runes[i.Value])}
+	case *object.Hash:
+		key, ok := index.(object.Hashable)
+		if !ok {
+			return newError("unusable as hash key: %s", index.Type())
+		}
+		if val, ok := left.Get(key); ok {
+			return val
+		}
+		return NULL
+	}
+	return newError("index operator not supported: %s", left.Type())
+}
+
+// evalMemberExpression resolves obj.name to a hash field or, failing that,
+// to a builtin method of obj. Fields shadow methods, so a hash with a "len"
+// key returns that value for m.len.
+func evalMemberExpression(obj object.Object, name string) object.Object {
+	if hash, ok := obj.(*object.Hash); ok {
+		if val, ok := hash.Get(&object.String{Value: name}); ok {
+			return val
+		}
+	}
+	if method := lookupMethod(obj, name); method != nil {
+		return method
+	}
+	if obj.Type() == object.HASH_OBJ {
+		return NULL
+	}
+	return newError("unknown member: %s.%s", obj.Type(), name)
+}
+
+// evalSliceExpression slices arrays and strings. Missing bounds default to
+// the start and end, and bounds outside the value are clamped to it, so
+// slicing never fails for integer bounds.
+func evalSliceExpression(node *ast.SliceExpression, env *object.Environment) object.Object {
+	left := Eval(node.Left, env)
+	if stopsEvaluation(left) {
+		return left
+	}
+
+	var length int64
+	switch left := left.(type) {
+	case *object.Array:
+		length = int64(len(left.Elements))
+	case *object.String:
+		length = int64(len([]rune(left.Value)))
+	default:
+		return newError("slice operator not supported: %s", left.Type())
+	}
+
+	low, err := evalSliceBound(node.Low, 0, length, env)
+	if err != nil {
+		return err
+	}
+	high, err := evalSliceBound(node.High, length, length, env)
+	if err != nil {
+		return err
+	}
+	high = max(low, high)
+
+	if array, ok := left.(*object.Array); ok {
+		elements := make([]object.Object, high-low)
+		copy(elements, array.Elements[low:high])
+		return &object.Array{Elements: elements}
+	}
+	return &object.String{Value: string([]rune(left.(*object.String).Value)[low:high])}
+}
+
+// evalSliceBound evaluates one bound of a slice, clamped to [0, length].
+func evalSliceBound(exp ast.Expression, missing, length int64, env *object.Environment) (int64, object.Object) {
+	if exp == nil {
+		return missing, nil
+	}
+	obj := Eval(exp, env)
+	if stopsEvaluation(obj) {
+		return 0, obj
+	}
+	i, ok := obj.(*object.Integer)
+	if !ok {
+		return 0, newError("slice bounds must be INTEGER, got %s", obj.Type())
+	}
+	return min(max(i.Value, 0), length), nil
+}
+
+func evalExpressions(exps []ast.Expression, env *object.Environment) []object.Object {
+	result := []object.Object{}
+	for _, e := range exps {
+		evaluated := Eval(e, env)
+		if stopsEvaluation(evaluated) {
+			return []object.Object{evaluated}
+		}
+		result = append(result, evaluated)
+	}
+	return result
+}
+
+func applyFunction(fn object.Object, args []object.Object) object.Object {
+	if builtin, ok := fn.(*object.Builtin); ok {
+		return builtin.Fn(args...)
+	}
+	function, ok := fn.(*object.Function)
+	if !ok {
+		return newError("not a function: %s", fn.Type())
+	}
+	if len(args) != len(function.Parameters) {
+		return newError("wrong number of arguments: expected %d, got %d",
+			len(function.Parameters), len(args))
+	}
+
+	// the depth is counted in the environment, so that separate programs
+	// can be evaluated concurrently
+	defer function.Env.ExitCall()
+	if function.Env.EnterCall() > MaxCallDepth {
+		return newError("maximum call depth exceeded")
+	}
+
+	env := object.NewEnclosedEnvironment(function.Env)
+	for i, param := range function.Parameters {
+		env.Set(param.Value, args[i])
+	}
+	evaluated := Eval(function.Body, env)
+	if returnValue, ok := evaluated.(*object.ReturnValue); ok {
+		return returnValue.Value
+	}
+	return evaluated
+}
+
+func nativeBoolToBooleanObject(input bool) *object.Boolean {
+	if input {
+		return TRUE
+	}
+	return FALSE
+}
+
+func isTruthy(obj object.Object) bool {
+	switch obj {
+	case NULL:
+		return false
+	case TRUE:
+		return true
+	case FALSE:
+		return false
+	}
+	return true
+}
+
+func newError(format string, a ...any) *object.Error {
+	return &object.Error{Message: fmt.Sprintf(format, a...)}
+}
+
+func isError(obj object.Object) bool {
+	return obj != nil && obj.Type() == object.ERROR_OBJ
+}
+
+// stopsEvaluation reports whether obj is an error, a return value, break or
+// continue, which end the evaluation of every enclosing expression and
+// statement until the loop, function or program is reached. An if used as
+// an expression can return, break or continue.
+func stopsEvaluation(obj object.Object) bool {
+	if obj == nil {
+		return false
+	}
+	switch obj.Type() {
+	case object.ERROR_OBJ, object.RETURN_VALUE_OBJ, object.BREAK_OBJ, object.CONTINUE_OBJ:
+		return true
+	}
+	return false
+}
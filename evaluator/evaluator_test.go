@@ -0,0 +1,900 @@
+package evaluator
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"ljos.app/interpreter/lexer"
+	"ljos.app/interpreter/object"
+	"ljos.app/interpreter/parser"
+)
+
+func testEval(input string) object.Object {
+	l := lexer.New(input)
+	p := parser.New(l)
+	program := p.ParseProgram()
+	env := object.NewEnvironment()
+	return Eval(program, env)
+}
+
+func testIntegerObject(t *testing.T, obj object.Object, expected int64) bool {
+	result, ok := obj.(*object.Integer)
+	if !ok {
+		t.Errorf("object is not Integer. got=%T (%+v)", obj, obj)
+		return false
+	}
+	if result.Value != expected {
+		t.Errorf("object has wrong value. got=%d, want=%d", result.Value, expected)
+		return false
+	}
+	return true
+}
+
+func testBooleanObject(t *testing.T, obj object.Object, expected bool) bool {
+	result, ok := obj.(*object.Boolean)
+	if !ok {
+		t.Errorf("object is not Boolean. got=%T (%+v)", obj, obj)
+		return false
+	}
+	if result.Value != expected {
+		t.Errorf("object has wrong value. got=%t, want=%t", result.Value, expected)
+		return false
+	}
+	return true
+}
+
+func testNullObject(t *testing.T, obj object.Object) bool {
+	if obj != NULL {
+		t.Errorf("object is not NULL. got=%T (%+v)", obj, obj)
+		return false
+	}
+	return true
+}
+
+func TestEvalIntegerExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"5", 5},
+		{"10", 10},
+		{"-5", -5},
+		{"-10", -10},
+		{"5 + 5 + 5 + 5 - 10", 10},
+		{"2 * 2 * 2 * 2 * 2", 32},
+		{"-50 + 100 + -50", 0},
+		{"5 * 2 + 10", 20},
+		{"5 + 2 * 10", 25},
+		{"20 + 2 * -10", 0},
+		{"50 / 2 * 2 + 10", 60},
+		{"2 * (5 + 10)", 30},
+		{"3 * 3 * 3 + 10", 37},
+		{"(5 + 10 * 2 + 15 / 3) * 2 + -10", 50},
+		{"7 % 3", 1},
+		{"-7 % 3", -1},
+		{"2 ** 10", 1024},
+		{"2 ** 3 ** 2", 512},
+		{"-2 ** 2", -4},
+		{"5 ** 0", 1},
+		{"6 & 3", 2},
+		{"6 | 3", 7},
+		{"6 ^ 3", 5},
+		{"1 << 4", 16},
+		{"-16 >> 2", -4},
+		{"1 + 1 << 2", 8},
+		{"2 | 1 * 4", 6},
+		{"7 & 3 + 1", 4},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		testIntegerObject(t, evaluated, tt.expected)
+	}
+}
+
+func TestEvalBooleanExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{"true", true},
+		{"false", false},
+		{"1 < 2", true},
+		{"1 > 2", false},
+		{"1 <= 1", true},
+		{"2 >= 3", false},
+		{"1 == 1", true},
+		{"1 != 1", false},
+		{"true == true", true},
+		{"true != false", true},
+		{"(1 < 2) == true", true},
+		{"(1 > 2) == true", false},
+		{"true && true", true},
+		{"true && false", false},
+		{"false || true", true},
+		{"false || false", false},
+		{"1 < 2 && 2 < 3", true},
+		{"1 && 0", true},
+		{"false || 0", true},
+		{"let x = 5; x & 1 == 1", true},
+		{"6 ^ 2 == 4", true},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		testBooleanObject(t, evaluated, tt.expected)
+	}
+}
+
+func TestBangOperator(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{"!true", false},
+		{"!false", true},
+		{"!5", false},
+		{"!!true", true},
+		{"!!5", true},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		testBooleanObject(t, evaluated, tt.expected)
+	}
+}
+
+func TestIfElseExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected any
+	}{
+		{"if (true) { 10 }", 10},
+		{"if (false) { 10 }", nil},
+		{"if (1 < 2) { 10 } else { 20 }", 10},
+		{"if (1 > 2) { 10 } else { 20 }", 20},
+		{"if (1 > 2) { 10 } else if (1 == 1) { 15 } else { 20 }", 15},
+		{"if (1 > 2) { 10 } else if (1 == 2) { 15 }", nil},
+		{"let x = if (1 > 2) { 10 } else { 20 }; x", 20},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		integer, ok := tt.expected.(int)
+		if ok {
+			testIntegerObject(t, evaluated, int64(integer))
+		} else {
+			testNullObject(t, evaluated)
+		}
+	}
+}
+
+func TestReturnStatements(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"return 10;", 10},
+		{"return 10; 9;", 10},
+		{"return 2 * 5; 9;", 10},
+		{"9; return 2 * 5; 9;", 10},
+		{`
+  if (10 > 1) {
+    if (10 > 1) {
+      return 10;
+    }
+    return 1;
+  }
+  `, 10},
+		// a return inside an if used as a value leaves the function
+		{"let f = fn() { let x = if (true) { return 1; } else { 2 }; 5 }; f()", 1},
+		{"let f = fn() { 10 + if (true) { return 1; } else { 2 } }; f()", 1},
+		{"let f = fn(g) { g(if (true) { return 1; }) }; f(fn(x) { 5 })", 1},
+		{"let f = fn() { let xs = [1, if (true) { return 2; }]; 5 }; f()", 2},
+		{"let x = 0; let f = fn() { x = if (true) { return 3; }; 5 }; f()", 3},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		testIntegerObject(t, evaluated, tt.expected)
+	}
+}
+
+func TestCallDepth(t *testing.T) {
+	evaluated := testEval("let f = fn(n) { f(n + 1) }; f(0)")
+	errObj, ok := evaluated.(*object.Error)
+	if !ok {
+		t.Fatalf("no error object returned. got=%T (%+v)", evaluated, evaluated)
+	}
+	if errObj.Message != "maximum call depth exceeded" {
+		t.Errorf("wrong error message. got=%q", errObj.Message)
+	}
+
+	// the depth is restored after the error, so deep but finite recursion
+	// still works
+	evaluated = testEval("let count = fn(n) { if (n == 0) { 0 } else { 1 + count(n - 1) } }; count(5000)")
+	testIntegerObject(t, evaluated, 5000)
+}
+
+// Each program counts its own calls, so programs evaluated concurrently
+// neither race on the count nor use up each other's depth.
+func TestCallDepthConcurrent(t *testing.T) {
+	input := fmt.Sprintf("let f = fn(n) { if (n == 0) { 0 } else { 1 + f(n - 1) } }; f(%d)", MaxCallDepth-1)
+
+	results := make(chan object.Object)
+	for i := 0; i < 4; i++ {
+		go func() { results <- testEval(input) }()
+	}
+	for i := 0; i < 4; i++ {
+		testIntegerObject(t, <-results, MaxCallDepth-1)
+	}
+}
+
+func TestErrorHandling(t *testing.T) {
+	tests := []struct {
+		input           string
+		expectedMessage string
+	}{
+		{"5 + true;", "type mismatch: INTEGER + BOOLEAN"},
+		{"5 + true; 5;", "type mismatch: INTEGER + BOOLEAN"},
+		{"-true", "unknown operator: -BOOLEAN"},
+		{"true + false;", "unknown operator: BOOLEAN + BOOLEAN"},
+		{"if (10 > 1) { true + false; }", "unknown operator: BOOLEAN + BOOLEAN"},
+		{"foobar", "identifier not found: foobar"},
+		{"10 / 0", "division by zero: 10 / 0"},
+		{"let f = fn(x) { x }; f(1, 2)", "wrong number of arguments: expected 1, got 2"},
+		{"5(1)", "not a function: INTEGER"},
+		{"let x = 1 + ;", "cannot evaluate code with syntax errors"},
+		{`"Hello" - "World"`, "unknown operator: STRING - STRING"},
+		{`"Hello" + 1`, "type mismatch: STRING + INTEGER"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		errObj, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("no error object returned. got=%T(%+v)", evaluated, evaluated)
+			continue
+		}
+		if errObj.Message != tt.expectedMessage {
+			t.Errorf("wrong error message. expected=%q, got=%q", tt.expectedMessage, errObj.Message)
+		}
+	}
+}
+
+func TestLetStatements(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"let a = 5; a;", 5},
+		{"let a = 5 * 5; a;", 25},
+		{"let a = 5; let b = a; b;", 5},
+		{"let a = 5; let b = a; let c = a + b + 5; c;", 15},
+	}
+
+	for _, tt := range tests {
+		testIntegerObject(t, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestFunctionApplication(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"let identity = fn(x) { x; }; identity(5);", 5},
+		{"let identity = fn(x) { return x; }; identity(5);", 5},
+		{"let double = fn(x) { x * 2; }; double(5);", 10},
+		{"let add = fn(x, y) { x + y; }; add(5, 5);", 10},
+		{"let add = fn(x, y) { x + y; }; add(5 + 5, add(5, 5));", 20},
+		{"fn(x) { x; }(5)", 5},
+		{"let add = (x, y) => x + y; add(2, 3)", 5},
+		{"let seven = () => { return 7; }; seven()", 7},
+	}
+
+	for _, tt := range tests {
+		testIntegerObject(t, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestClosures(t *testing.T) {
+	input := `
+  let newAdder = fn(x) {
+    fn(y) { x + y };
+  };
+
+  let addTwo = newAdder(2);
+  addTwo(2);`
+
+	testIntegerObject(t, testEval(input), 4)
+}
+
+func TestRecursiveFunction(t *testing.T) {
+	input := `
+  let fib = fn(n) {
+    if (n < 2) { return n; }
+    fib(n - 1) + fib(n - 2);
+  };
+  fib(10);`
+
+	testIntegerObject(t, testEval(input), 55)
+}
+
+func TestErrorSpans(t *testing.T) {
+	input := "let x = 5;\nlet y = x + true;"
+
+	evaluated := testEval(input)
+	errObj, ok := evaluated.(*object.Error)
+	if !ok {
+		t.Fatalf("no error object returned. got=%T(%+v)", evaluated, evaluated)
+	}
+	d := errObj.Diagnostic()
+	expected := "2:9: error[R0001]: type mismatch: INTEGER + BOOLEAN"
+	if d.Error() != expected {
+		t.Errorf("wrong diagnostic. expected=%q, got=%q", expected, d.Error())
+	}
+	if d.Span.End.Column != 17 {
+		t.Errorf("wrong span end column. expected=17, got=%d", d.Span.End.Column)
+	}
+}
+
+func TestStringLiteral(t *testing.T) {
+	evaluated := testEval(`"Hello World!"`)
+	str, ok := evaluated.(*object.String)
+	if !ok {
+		t.Fatalf("object is not String. got=%T (%+v)", evaluated, evaluated)
+	}
+	if str.Value != "Hello World!" {
+		t.Errorf("String has wrong value. got=%q", str.Value)
+	}
+}
+
+func TestStringConcatenation(t *testing.T) {
+	evaluated := testEval(`let greet = fn(name) { "Hello" + ", " + name + "!" }; greet("hua")`)
+	str, ok := evaluated.(*object.String)
+	if !ok {
+		t.Fatalf("object is not String. got=%T (%+v)", evaluated, evaluated)
+	}
+	if str.Value != "Hello, hua!" {
+		t.Errorf("String has wrong value. got=%q", str.Value)
+	}
+}
+
+func TestStringComparison(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{`"a" == "a"`, true},
+		{`"a" != "a"`, false},
+		{`"a" == "b"`, false},
+		{`"a" < "b"`, true},
+		{`"b" <= "a"`, false},
+		{`"abc" > "abb"`, true},
+		{`"x" + "y" == "xy"`, true},
+	}
+
+	for _, tt := range tests {
+		testBooleanObject(t, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestEvalFloatExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected float64
+	}{
+		{"3.14", 3.14},
+		{"-2.5", -2.5},
+		{"1.5 + 1.5", 3.0},
+		{"1 + 0.5", 1.5},
+		{"0.5 * 4", 2.0},
+		{"7 / 2.0", 3.5},
+		{"10 - 0.25", 9.75},
+		{"1e3 + 1", 1001},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		result, ok := evaluated.(*object.Float)
+		if !ok {
+			t.Errorf("input %s: object is not Float. got=%T (%+v)", tt.input, evaluated, evaluated)
+			continue
+		}
+		if result.Value != tt.expected {
+			t.Errorf("input %s: wrong value. got=%g, want=%g", tt.input, result.Value, tt.expected)
+		}
+	}
+}
+
+func TestMixedNumberComparison(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{"1 == 1.0", true},
+		{"1 < 1.5", true},
+		{"2.5 >= 3", false},
+		{"0.1 + 0.2 != 0.3", true},
+	}
+
+	for _, tt := range tests {
+		testBooleanObject(t, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestFloatInspect(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"3.0", "3.0"},
+		{"2.5", "2.5"},
+		{"1e21", "1e+21"},
+		{"1.0 / 0", "+Inf"},
+	}
+
+	for _, tt := range tests {
+		if got := testEval(tt.input).Inspect(); got != tt.expected {
+			t.Errorf("input %s: wrong Inspect(). expected=%q, got=%q", tt.input, tt.expected, got)
+		}
+	}
+}
+
+func TestAssignExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected any
+	}{
+		{"let x = 1; x = 5; x", 5},
+		{"let x = 1; x += 2; x", 3},
+		{"let x = 10; x -= 4; x", 6},
+		{"let x = 3; x *= 4; x", 12},
+		{"let x = 12; x /= 5; x", 2},
+		{"let x = 12; x %= 5; x", 2},
+		{"let x = 1; x = x + 1", 2},
+		{"let x = 1; let y = 2; x = y = 7; x + y", 14},
+		{`let s = "a"; s += "b"; s`, "ab"},
+		{"let x = 1.5; x *= 2; x", 3.0},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			str, ok := evaluated.(*object.String)
+			if !ok || str.Value != expected {
+				t.Errorf("input %s: expected %q, got %+v", tt.input, expected, evaluated)
+			}
+		case float64:
+			float, ok := evaluated.(*object.Float)
+			if !ok || float.Value != expected {
+				t.Errorf("input %s: expected %g, got %+v", tt.input, expected, evaluated)
+			}
+		}
+	}
+}
+
+func TestAssignToEnclosingScope(t *testing.T) {
+	input := `
+  let count = 0;
+  let increment = fn() { count += 1; };
+  increment();
+  increment();
+  let shadow = fn() { let count = 100; count = 200; };
+  shadow();
+  count;`
+
+	testIntegerObject(t, testEval(input), 2)
+}
+
+func TestAssignErrors(t *testing.T) {
+	tests := []struct {
+		input           string
+		expectedMessage string
+	}{
+		{"y = 1", "cannot assign to undeclared variable y, declare it with let first"},
+		{"let f = fn() { z += 1 }; f()", "cannot assign to undeclared variable z, declare it with let first"},
+		{"let x = 1; x += true", "type mismatch: INTEGER + BOOLEAN"},
+		{"let x = 1; x %= 0", "division by zero: 1 % 0"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		errObj, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("input %s: no error object returned. got=%T(%+v)", tt.input, evaluated, evaluated)
+			continue
+		}
+		if errObj.Message != tt.expectedMessage {
+			t.Errorf("input %s: wrong error message. expected=%q, got=%q", tt.input, tt.expectedMessage, errObj.Message)
+		}
+	}
+}
+
+func TestShortCircuitEvaluation(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{"false && undefined", false},
+		{"true || undefined", true},
+		{"let x = 0; let f = fn() { x += 1; true }; false && f(); x == 0", true},
+		{"let x = 0; let f = fn() { x += 1; true }; true && f(); x == 1", true},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		testBooleanObject(t, evaluated, tt.expected)
+	}
+}
+
+func TestBitwiseAndPowerOperators(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected any
+	}{
+		{"2 ** -1", 0.5},
+		{"2.0 ** 3", 8.0},
+		{"9 ** 0.5", 3.0},
+		{"let x = 3; x **= 2; x", 9},
+		{"let x = 12; x &= 10; x", 8},
+		{"let x = 12; x |= 3; x", 15},
+		{"let x = 12; x ^= 4; x", 8},
+		{"let x = 1; x <<= 3; x", 8},
+		{"let x = 8; x >>= 3; x", 1},
+		{"1 << -1", "negative shift count: 1 << -1"},
+		{"1.5 & 1", "unknown operator: FLOAT & INTEGER"},
+		{"true | false", "unknown operator: BOOLEAN | BOOLEAN"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case float64:
+			float, ok := evaluated.(*object.Float)
+			if !ok || float.Value != expected {
+				t.Errorf("input %s: expected %g, got %+v", tt.input, expected, evaluated)
+			}
+		case string:
+			errObj, ok := evaluated.(*object.Error)
+			if !ok || errObj.Message != expected {
+				t.Errorf("input %s: expected error %q, got %+v", tt.input, expected, evaluated)
+			}
+		}
+	}
+}
+
+func TestArrayLiterals(t *testing.T) {
+	input := "[1, 2 * 2, 3 + 3]"
+
+	evaluated := testEval(input)
+	result, ok := evaluated.(*object.Array)
+	if !ok {
+		t.Fatalf("object is not Array. got=%T (%+v)", evaluated, evaluated)
+	}
+	if len(result.Elements) != 3 {
+		t.Fatalf("array has wrong num of elements. got=%d", len(result.Elements))
+	}
+	testIntegerObject(t, result.Elements[0], 1)
+	testIntegerObject(t, result.Elements[1], 4)
+	testIntegerObject(t, result.Elements[2], 6)
+}
+
+func TestHashLiterals(t *testing.T) {
+	input := `let two = "two";
+	{
+		"one": 10 - 9,
+		two: 1 + 1,
+		"thr" + "ee": 6 / 2,
+		4: 4,
+		true: 5,
+		false: 6
+	}`
+
+	evaluated := testEval(input)
+	result, ok := evaluated.(*object.Hash)
+	if !ok {
+		t.Fatalf("Eval didn't return Hash. got=%T (%+v)", evaluated, evaluated)
+	}
+
+	expected := `{"one": 1, "two": 2, "three": 3, 4: 4, true: 5, false: 6}`
+	if result.Inspect() != expected {
+		t.Errorf("wrong hash. expected=%q, got=%q", expected, result.Inspect())
+	}
+}
+
+func TestIndexExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected any
+	}{
+		{"[1, 2, 3][0]", 1},
+		{"[1, 2, 3][1 + 1]", 3},
+		{"let i = 0; [1][i]", 1},
+		{"let myArray = [1, 2, 3]; myArray[0] + myArray[1] + myArray[2];", 6},
+		{"[1, 2, 3][3]", nil},
+		{"[1, 2, 3][-1]", nil},
+		{`{"foo": 5}["foo"]`, 5},
+		{`{"foo": 5}["bar"]`, nil},
+		{`let key = "foo"; {"foo": 5}[key]`, 5},
+		{`{}["foo"]`, nil},
+		{`{5: 5}[5]`, 5},
+		{`{true: 5}[true]`, 5},
+		{`{1: "int"}["1"]`, nil},
+		{`"héllo"[1]`, "é"},
+		{`"abc"[3]`, nil},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			str, ok := evaluated.(*object.String)
+			if !ok || str.Value != expected {
+				t.Errorf("input %s: expected %q, got %+v", tt.input, expected, evaluated)
+			}
+		default:
+			testNullObject(t, evaluated)
+		}
+	}
+}
+
+func TestSliceExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"[1, 2, 3, 4][1:3]", "[2, 3]"},
+		{"[1, 2, 3, 4][2:]", "[3, 4]"},
+		{"[1, 2, 3, 4][:2]", "[1, 2]"},
+		{"[1, 2, 3, 4][:]", "[1, 2, 3, 4]"},
+		{"[1, 2, 3, 4][-5:10]", "[1, 2, 3, 4]"},
+		{"[1, 2, 3, 4][3:1]", "[]"},
+		{`"héllo"[1:4]`, "éll"},
+		{`"hello"[3:]`, "lo"},
+		{"let xs = [1, 2, 3]; let ys = xs[:]; ys[0] = 9; xs", "[1, 2, 3]"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("input %s: expected %s, got %s", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestIndexAssignment(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let xs = [1, 2, 3]; xs[1] = 5; xs", "[1, 5, 3]"},
+		{"let xs = [1, 2, 3]; xs[2] *= 10; xs", "[1, 2, 30]"},
+		{`let m = {"a": 1}; m["b"] = 2; m["a"] += 10; m`, `{"a": 11, "b": 2}`},
+		{"let xs = [[1], [2]]; xs[1][0] = 3; xs", "[[1], [3]]"},
+		{"let xs = [1]; let f = fn(a) { a[0] = 2 }; f(xs); xs", "[2]"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("input %s: expected %s, got %s", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestCollectionErrors(t *testing.T) {
+	tests := []struct {
+		input           string
+		expectedMessage string
+	}{
+		{`{"name": "Monkey"}[fn(x) { x }];`, "unusable as hash key: FUNCTION"},
+		{`{[1]: 2}`, "unusable as hash key: ARRAY"},
+		{`{1.5: 2}`, "unusable as hash key: FLOAT"},
+		{`[1, 2]["a"]`, "array index must be INTEGER, got STRING"},
+		{`5[0]`, "index operator not supported: INTEGER"},
+		{`5[0:1]`, "slice operator not supported: INTEGER"},
+		{`[1, 2][true:]`, "slice bounds must be INTEGER, got BOOLEAN"},
+		{`let xs = [1]; xs[1] = 2`, "index out of range: 1 with length 1"},
+		{`let s = "ab"; s[0] = "c"`, "index assignment not supported: STRING"},
+		{`[1, foo]`, "identifier not found: foo"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		errObj, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("input %s: no error object returned. got=%T(%+v)", tt.input, evaluated, evaluated)
+			continue
+		}
+		if errObj.Message != tt.expectedMessage {
+			t.Errorf("input %s: wrong error message. expected=%q, got=%q", tt.input, tt.expectedMessage, errObj.Message)
+		}
+	}
+}
+
+func TestBuiltinMethods(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`"héllo".len()`, "5"},
+		{`"Hello".upper()`, "HELLO"},
+		{`"Hello".lower()`, "hello"},
+		{`"  hi  ".trim()`, "hi"},
+		{`"a,b,c".split(",")`, `["a", "b", "c"]`},
+		{`"hello".contains("ell")`, "true"},
+		{`"hello".contains("z")`, "false"},
+		{`[1, 2, 3].len()`, "3"},
+		{`let xs = [1, 2]; xs.push(3); xs`, "[1, 2, 3]"},
+		{`[].push(1).push(2)`, "[1, 2]"},
+		{`let xs = [1, 2]; let last = xs.pop(); [last, xs]`, "[2, [1]]"},
+		{`[].pop()`, "null"},
+		{`[1, 2, 3].first()`, "1"},
+		{`[1, 2, 3].last()`, "3"},
+		{`[].first()`, "null"},
+		{`[1, "a", true].join("-")`, "1-a-true"},
+		{`[1, 2, 3].map(fn(x) { x * 2 })`, "[2, 4, 6]"},
+		{`[1, 2, 3, 4].filter((x) => x % 2 == 0)`, "[2, 4]"},
+		{`[1, 2, 3, 4].filter((x) => x > 1).map((x) => x * x).len()`, "3"},
+		{`{"a": 1, "b": 2}.keys()`, `["a", "b"]`},
+		{`{"a": 1, "b": 2}.values()`, "[1, 2]"},
+		{`{"a": 1, "b": 2}.len()`, "2"},
+		{`{"a": 1}.has("a")`, "true"},
+		{`{"a": 1}.has("b")`, "false"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("input %s: expected %s, got %s", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestPuts(t *testing.T) {
+	var out bytes.Buffer
+	program := parser.New(lexer.New(`puts(1, "two", [3]); puts()`)).ParseProgram()
+	result := Eval(program, NewGlobalEnvironment(&out))
+
+	testNullObject(t, result)
+	if out.String() != "1\ntwo\n[3]\n" {
+		t.Errorf("wrong output. got=%q", out.String())
+	}
+}
+
+func TestHashMembers(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`let p = {"name": "Ada", "age": 36}; p.name`, "Ada"},
+		{`let p = {"name": "Ada"}; p.missing`, "null"},
+		{`let p = {"len": 7}; p.len`, "7"},
+		{`let p = {"greet": fn(n) { "hi " + n }}; p.greet("Ada")`, "hi Ada"},
+		{`let p = {"age": 36}; p.age += 1; p.name = "Ada"; p`, `{"age": 37, "name": "Ada"}`},
+		{`let p = {"inner": {"x": 1}}; p.inner.x = 2; p.inner.x`, "2"},
+		{`let m = {}; m.count = 0; m.count += 1; m.count`, "1"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("input %s: expected %s, got %s", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestMemberErrors(t *testing.T) {
+	tests := []struct {
+		input           string
+		expectedMessage string
+	}{
+		{`5.len()`, "unknown member: INTEGER.len"},
+		{`"abc".push(1)`, "unknown member: STRING.push"},
+		{`[1].len(2)`, "wrong number of arguments: expected 0, got 1"},
+		{`"a,b".split(1)`, "argument to split must be STRING, got INTEGER"},
+		{`[1].map(fn(x) { x + true })`, "type mismatch: INTEGER + BOOLEAN"},
+		{`[1].map(5)`, "not a function: INTEGER"},
+		{`let xs = [1]; xs.len = 2`, "member assignment not supported: ARRAY"},
+		{`{}.has([1])`, "unusable as hash key: ARRAY"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		errObj, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("input %s: no error object returned. got=%T(%+v)", tt.input, evaluated, evaluated)
+			continue
+		}
+		if errObj.Message != tt.expectedMessage {
+			t.Errorf("input %s: wrong error message. expected=%q, got=%q", tt.input, tt.expectedMessage, errObj.Message)
+		}
+	}
+}
+
+func TestWhileLoops(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let i = 0; while (i < 5) { i += 1 }; i", "5"},
+		{"let i = 0; while (false) { i += 1 }; i", "0"},
+		{"while (false) { 1 }", "null"},
+		{"let i = 0; while (true) { i += 1; if (i == 3) { break } }; i", "3"},
+		{"let i = 0; let sum = 0; while (i < 10) { i += 1; if (i % 2 == 0) { continue } sum += i }; sum", "25"},
+		{"let i = 0; while (i < 100000) { i += 1 }; i", "100000"},
+		{"let f = fn() { let i = 0; while (true) { i += 1; if (i == 4) { return i * 10 } } }; f()", "40"},
+		// break and continue inside an if used as a value still reach the loop
+		{"let xs = []; let i = 0; while (i < 3) { i += 1; xs.push(if (i == 2) { continue; } else { i }); }; xs", "[1, 3]"},
+		{"let y = 0; while (true) { let z = if (y > 3) { break; } else { y += 1 }; }; y", "4"},
+		{"let n = 0; for (x in [1, 2, 3]) { n = n + if (x == 2) { break } else { x } }; n", "1"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("input %s: expected %s, got %s", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestForLoops(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let sum = 0; for (x in [1, 2, 3]) { sum += x }; sum", "6"},
+		{`let ks = []; for (k in {"a": 1, "b": 2}) { ks.push(k) }; ks`, `["a", "b"]`},
+		{`let m = {"a": 1, "b": 2}; let total = 0; for (k in m) { total += m[k] }; total`, "3"},
+		{`let cs = []; for (c in "hé") { cs.push(c) }; cs`, `["h", "é"]`},
+		{"let out = []; for (x in [1, 2, 3, 4]) { if (x == 3) { break } out.push(x) }; out", "[1, 2]"},
+		{"let out = []; for (x in [1, 2, 3, 4]) { if (x % 2 == 1) { continue } out.push(x) }; out", "[2, 4]"},
+		{"let out = []; for (x in [1, 2]) { for (y in [1, 2]) { if (y == 2) { break } out.push([x, y]) } }; out", "[[1, 1], [2, 1]]"},
+		{"let xs = [1, 2]; for (x in xs) { xs.push(x) }; xs", "[1, 2, 1, 2]"},
+		{"let fs = []; for (x in [1, 2]) { fs.push(() => x) }; fs.map((f) => f())", "[1, 2]"},
+		{"let x = 10; for (x in [1]) { }; x", "10"},
+		{"for (x in []) { 1 }", "null"},
+		{"let find = fn(xs, v) { for (x in xs) { if (x == v) { return true } } false }; find([1, 2], 2)", "true"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("input %s: expected %s, got %s", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestLoopErrors(t *testing.T) {
+	tests := []struct {
+		input           string
+		expectedMessage string
+	}{
+		{"for (x in 5) { }", "cannot iterate over INTEGER"},
+		{"while (foo) { }", "identifier not found: foo"},
+		{"let i = 0; while (true) { i += true }", "type mismatch: INTEGER + BOOLEAN"},
+		{"for (x in [1]) { y }", "identifier not found: y"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		errObj, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("input %s: no error object returned. got=%T(%+v)", tt.input, evaluated, evaluated)
+			continue
+		}
+		if errObj.Message != tt.expectedMessage {
+			t.Errorf("input %s: wrong error message. expected=%q, got=%q", tt.input, tt.expectedMessage, errObj.Message)
+		}
+	}
+}
@@ -1,18 +1,37 @@
 package lexer
 
 import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+	"strconv"
 	"strings"
+	"unicode"
+	"unicode/utf8"
 
+	"ljos.app/interpreter/diag"
 	token "ljos.app/interpreter/token"
 )
 
+// Lexer reads tokens from a stream of source text. It only keeps the text of
+// the token being read, so the source does not need to fit in memory.
 type Lexer struct {
-	input          string
-	position       int // current position in input (points to current char)
-	readPosition   int // current reading position in input (after current char)
-	lines          []string
-	CurrentLineIdx int // current line, used for errors
-	ch             byte
+	file         string
+	reader       *bufio.Reader
+	position     int            // byte offset of the current char
+	readPosition int            // byte offset after the current char
+	line         int            // line of the current char, starting at 1
+	column       int            // column of the current char in runes, starting at 1
+	ch           rune           // current char, utf8.RuneError for invalid UTF-8
+	raw          []byte         // bytes the current char was decoded from
+	eof          bool           // whether the input is exhausted
+	invalid      bool           // whether ch was decoded from invalid UTF-8
+	start        token.Position // position of the first char of the current token
+	text         []byte         // source of the current token read so far
+	errors       []diag.Diagnostic
+	comments     []token.Token
 }
 type TokenLambda func() token.Token
 
@@ -22,29 +41,70 @@ func newToken(input string, tokenType token.TokenType) TokenLambda {
 	}
 }
 
-func isLetter(ch byte) bool {
-	return 'a' <= ch && ch <= 'z' || 'A' <= ch && ch <= 'Z' || ch == '_'
+func isLetter(ch rune) bool {
+	return 'a' <= ch && ch <= 'z' || 'A' <= ch && ch <= 'Z' || ch == '_' ||
+		ch >= utf8.RuneSelf && unicode.IsLetter(ch)
 }
 
-func isWhiteSpace(ch byte) bool {
+func isWhiteSpace(ch rune) bool {
 	isWhiteSpace := ch == ' ' || ch == '\t' || ch == '\n' || ch == '\r'
 	return isWhiteSpace
 }
 
-func isNumber(ch byte) bool {
+// isIdentifierDigit reports whether ch is a digit in any script, which may
+// appear in identifiers after the first char.
+func isIdentifierDigit(ch rune) bool {
+	return isNumber(ch) || ch >= utf8.RuneSelf && unicode.IsDigit(ch)
+}
+
+// isNumber reports whether ch is an ASCII digit, number literals do not
+// accept digits from other scripts.
+func isNumber(ch rune) bool {
 	return ch <= '9' && ch >= '0'
 }
 
+func isHexDigit(ch rune) bool {
+	return isNumber(ch) || 'a' <= ch && ch <= 'f' || 'A' <= ch && ch <= 'F'
+}
+
+func isDigit(ch rune, base int) bool {
+	switch base {
+	case 2:
+		return ch == '0' || ch == '1'
+	case 8:
+		return '0' <= ch && ch <= '7'
+	case 16:
+		return isHexDigit(ch)
+	}
+	return isNumber(ch)
+}
+
 func New(input string) *Lexer {
-	l := &Lexer{input: input}
-	lines := strings.Split(input, "\n")
-	l.lines = lines
-	l.CurrentLineIdx = 0
-	l.readChar()
+	return NewFile("", input)
+}
+
+// NewFile creates a lexer whose token positions refer to the named file.
+func NewFile(file string, input string) *Lexer {
+	return NewFileReader(file, strings.NewReader(input))
+}
+
+// NewReader creates a lexer reading its input from r as tokens are asked
+// for. It produces the same tokens as New on the whole input.
+func NewReader(r io.Reader) *Lexer {
+	return NewFileReader("", r)
+}
+
+// NewFileReader creates a lexer reading from r whose token positions refer to
+// the named file.
+func NewFileReader(file string, r io.Reader) *Lexer {
+	l := &Lexer{file: file, reader: bufio.NewReader(r)}
+	l.line = 1
+	l.column = 1
+	l.decodeChar()
 	return l
 }
 
-func getToken(ch byte) (TokenLambda, bool) {
+func getToken(ch rune) (TokenLambda, bool) {
 	val, ok := tokenMap[string(ch)]
 	return val, ok
 }
@@ -54,162 +114,496 @@ func getTokenFromString(identifier string) (TokenLambda, bool) {
 	return val, ok
 }
 
-var multiCharTokenMap = map[byte]struct{}{
-	'=': {},
-	'<': {},
-	'>': {},
-	'!': {},
-	'-': {},
-	'*': {},
-	'+': {},
-	'/': {},
-}
+// tokenMap holds every operator and delimiter. Every prefix of an operator
+// must be in the map as well, since operators are matched by extending one
+// char at a time.
 var tokenMap = map[string]TokenLambda{
-	"=":      newToken("=", token.ASSIGN),
-	";":      newToken(";", token.SEMICOLON),
-	"(":      newToken("(", token.LPAREN),
-	")":      newToken(")", token.RPAREN),
-	"{":      newToken("{", token.LBRACE),
-	"}":      newToken("}", token.RBRACE),
-	"[":      newToken("[", token.LBRACK),
-	"]":      newToken("]", token.RBRACK),
-	",":      newToken(",", token.COMMA),
-	".":      newToken(".", token.DOT),
-	"+":      newToken("+", token.PLUS),
-	"-":      newToken("-", token.MINUS),
-	"!":      newToken("!", token.BANG),
-	"<":      newToken("<", token.LESS_THAN),
-	">":      newToken(">", token.GREATER_THAN),
-	"/":      newToken("/", token.SLASH),
-	"*":      newToken("*", token.ASTERISK),
-	"+=":     newToken("+=", token.PLUS),
-	"-=":     newToken("-=", token.MINUS_ASSIGN),
-	"!=":     newToken("!=", token.NOT_EQUAL),
-	"<=":     newToken("<=", token.LESS_THAN_EQ),
-	">=":     newToken(">=", token.GRTR_THAN_EQ),
-	"/=":     newToken("/=", token.SLASH),
-	"*=":     newToken("*=", token.MUL_ASSIGN),
-	"==":     newToken("==", token.EQUAL),
-	"=>":     newToken("=>", token.LAMBDA),
-	"let":    newToken("let", token.LET),
-	"fn":     newToken("fn", token.FUNCTION),
-	"return": newToken("return", token.RETURN),
-	"if":     newToken("if", token.IF),
-	"else":   newToken("else", token.ELSE),
-	"true":   newToken("true", token.TRUE),
-	"false":  newToken("false", token.FALSE),
+	"=":  newToken("=", token.ASSIGN),
+	";":  newToken(";", token.SEMICOLON),
+	"(":  newToken("(", token.LPAREN),
+	")":  newToken(")", token.RPAREN),
+	"{":  newToken("{", token.LBRACE),
+	"}":  newToken("}", token.RBRACE),
+	"[":  newToken("[", token.LBRACK),
+	"]":  newToken("]", token.RBRACK),
+	",":  newToken(",", token.COMMA),
+	":":  newToken(":", token.COLON),
+	".":  newToken(".", token.DOT),
+	"+":  newToken("+", token.PLUS),
+	"-":  newToken("-", token.MINUS),
+	"!":  newToken("!", token.BANG),
+	"<":  newToken("<", token.LESS_THAN),
+	">":  newToken(">", token.GREATER_THAN),
+	"/":  newToken("/", token.SLASH),
+	"*":  newToken("*", token.ASTERISK),
+	"+=": newToken("+=", token.PLUS_ASSIGN),
+	"-=": newToken("-=", token.MINUS_ASSIGN),
+	"!=": newToken("!=", token.NOT_EQUAL),
+	"<=": newToken("<=", token.LESS_THAN_EQ),
+	">=": newToken(">=", token.GRTR_THAN_EQ),
+	"/=": newToken("/=", token.DIV_ASSIGN),
+	"%=": newToken("%=", token.MOD_ASSIGN),
+	"*=": newToken("*=", token.MUL_ASSIGN),
+	"==": newToken("==", token.EQUAL),
+	"=>": newToken("=>", token.LAMBDA),
+	"%":  newToken("%", token.PERCENT),
+	"&":  newToken("&", token.AMPERSAND),
+	"|":  newToken("|", token.PIPE),
+	"^":  newToken("^", token.CARET),
+	"**": newToken("**", token.POWER),
+	"<<": newToken("<<", token.SHIFT_LEFT),
+	">>": newToken(">>", token.SHIFT_RIGHT),
+	"&&": newToken("&&", token.LOGICAL_AND),
+	"||": newToken("||", token.LOGICAL_OR),
+	"&=": newToken("&=", token.AND_ASSIGN),
+	"|=": newToken("|=", token.OR_ASSIGN),
+	"^=": newToken("^=", token.XOR_ASSIGN),
+
+	"**=": newToken("**=", token.POW_ASSIGN),
+	"<<=": newToken("<<=", token.SHL_ASSIGN),
+	">>=": newToken(">>=", token.SHR_ASSIGN),
 }
 
+// readChar advances to the next char, keeping track of line and column.
 func (l *Lexer) readChar() {
-	if l.readPosition >= len(l.input) {
-		l.ch = 0
+	if l.atEOF() {
+		return
+	}
+	l.text = append(l.text, l.raw...)
+	if l.ch == '\n' {
+		l.line += 1
+		l.column = 1
 	} else {
-		l.ch = l.input[l.readPosition]
+		l.column += 1
 	}
+	l.decodeChar()
+}
+
+// decodeChar reads the next rune from the input and makes it the current
+// char. Bytes that are not valid UTF-8 are reported and become
+// utf8.RuneError.
+func (l *Lexer) decodeChar() {
 	l.position = l.readPosition
-	l.readPosition += 1
+	l.invalid = false
+	buf, err := l.peekBytes()
+	if len(buf) == 0 {
+		l.ch = 0
+		l.raw = l.raw[:0]
+		l.eof = true
+		if err != nil && !errors.Is(err, io.EOF) {
+			span := token.Span{Start: l.currentPosition(), End: l.currentPosition()}
+			l.errors = append(l.errors, diag.Errorf(diag.ReadFailed, span, "reading input failed: %s", err))
+		}
+		return
+	}
+	ch, width := utf8.DecodeRune(buf)
+	l.ch = ch
+	l.raw = append(l.raw[:0], buf[:width]...)
+	l.reader.Discard(width)
+	l.readPosition += width
+	if ch == utf8.RuneError && width == 1 {
+		l.invalid = true
+		from := l.currentPosition()
+		to := from
+		to.Column += 1
+		to.Offset += 1
+		l.errors = append(l.errors, diag.Errorf(diag.InvalidUTF8, token.Span{Start: from, End: to},
+			"invalid UTF-8 encoding %q", l.raw))
+	}
 }
 
-func (l *Lexer) GetCurrentLines() []string {
-	lines := make([]string, 3)
-	lines[1] = l.lines[l.CurrentLineIdx] + "       // <-------"
-	if l.CurrentLineIdx > 0 {
-		lines[0] = l.lines[l.CurrentLineIdx-1]
+// peekBytes returns the bytes of the next rune in the input without
+// consuming them, or fewer if the input ends first. It only waits for as
+// many bytes as the rune needs, so tokens are available as soon as they
+// have streamed in.
+func (l *Lexer) peekBytes() ([]byte, error) {
+	var buf []byte
+	var err error
+	for n := 1; n <= utf8.UTFMax; n++ {
+		buf, err = l.reader.Peek(n)
+		if err != nil || utf8.FullRune(buf) {
+			break
+		}
 	}
-	if l.CurrentLineIdx+1 < len(l.lines) {
-		lines[2] = l.lines[l.CurrentLineIdx+1]
+	return buf, err
+}
+
+// currentPosition is the position of the current char.
+func (l *Lexer) currentPosition() token.Position {
+	return token.Position{
+		File:   l.file,
+		Line:   l.line,
+		Column: l.column,
+		Offset: l.position,
 	}
-	return lines
 }
+
 func (l *Lexer) NextToken() token.Token {
+	l.skipTrivia()
+	l.startToken()
+	tok := l.scanToken()
+	tok.Pos = l.start
+	tok.End = l.currentPosition()
+	return tok
+}
+
+// All returns an iterator over the remaining tokens, stopping before EOF.
+// ILLEGAL tokens are included, with their diagnostics in Errors.
+func (l *Lexer) All() iter.Seq[token.Token] {
+	return func(yield func(token.Token) bool) {
+		for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+			if !yield(tok) {
+				return
+			}
+		}
+	}
+}
+
+// Tokenize returns the tokens of src up to but not including EOF. Illegal
+// characters and malformed literals are left out of the tokens and reported
+// in the diagnostics instead.
+func Tokenize(src string) ([]token.Token, []diag.Diagnostic) {
+	l := New(src)
+	tokens := []token.Token{}
+	for tok := range l.All() {
+		if tok.Type != token.ILLEGAL {
+			tokens = append(tokens, tok)
+		}
+	}
+	return tokens, l.Errors()
+}
+
+// startToken marks the current char as the start of a token or comment.
+func (l *Lexer) startToken() {
+	l.start = l.currentPosition()
+	l.text = l.text[:0]
+}
+
+func (l *Lexer) errorf(code diag.Code, from token.Position, format string, args ...any) {
+	span := token.Span{Start: from, End: l.currentPosition()}
+	l.errors = append(l.errors, diag.Errorf(code, span, format, args...))
+}
+
+// Comments returns the comments skipped over so far, in source order.
+func (l *Lexer) Comments() []token.Token {
+	return l.comments
+}
+
+// Errors returns the diagnostics for the input lexed so far.
+func (l *Lexer) Errors() []diag.Diagnostic {
+	return l.errors
+}
+
+func (l *Lexer) scanToken() token.Token {
 
 	var tok token.Token
-	l.skipWhiteSpace()
 
 	if isNumber(l.ch) {
-		tok.Literal = l.readNumber()
-		tok.Type = token.INT
+		tok.Type, tok.Literal = l.readNumber()
+		return tok
+	}
+
+	if l.ch == '"' {
+		tok.Literal = l.readString()
+		tok.Type = token.STRING
 		return tok
 	}
 
 	if val, ok := getToken(l.ch); ok {
-		t := val()
-		defer l.readChar()
-		// if its a normal token then we need to check if it is a multi char token like ==
-		if _, ok := multiCharTokenMap[l.ch]; ok {
-			multiCharToken := string(l.ch)
-			nextChar := l.peekChar()
-			if nextChar == 0 {
-				return t
-			}
-			multiCharToken += string(nextChar)
-			if val, ok = getTokenFromString(multiCharToken); ok {
-				// read an additional char for multiCharToken
-				// this only works if the multi char token i of length 2
-				l.readChar()
-				return val()
+		// take the longest operator, e.g. <<= rather than < followed by <=
+		operator := string(l.ch)
+		for next := l.peekChar(); next != 0; next = l.peekChar() {
+			longer, ok := getTokenFromString(operator + string(next))
+			if !ok {
+				break
 			}
+			l.readChar()
+			operator += string(next)
+			val = longer
 		}
-		return t
+		l.readChar()
+		return val()
 	}
 
 	if isLetter(l.ch) {
 		tok.Literal = l.readIdentifier()
-		if v, ok := getTokenFromString(tok.Literal); !ok {
-			tok.Type = token.IDENTIFIER
-		} else {
-			tok = v()
-		}
-
+		tok.Type = token.LookupIdent(tok.Literal)
 		return tok
 	}
 
-	if l.ch == 0 {
+	if l.atEOF() {
 		tok.Type = token.EOF
 		tok.Literal = ""
 		return tok
 	}
 
-	tok.Literal = string(l.ch)
+	tok.Literal = string(l.raw)
 	tok.Type = token.ILLEGAL
+	invalid := l.invalid
+	l.readChar()
+	if !invalid {
+		// invalid UTF-8 is reported when decoded
+		l.errorf(diag.IllegalCharacter, l.start, "illegal character %q", tok.Literal)
+	}
 
 	return tok
 }
 
 func (l *Lexer) readIdentifier() string {
-	position := l.position
-
-	for isLetter(l.ch) {
+	for isLetter(l.ch) || isIdentifierDigit(l.ch) {
 		l.readChar()
 	}
-	return l.input[position:l.position]
+	return string(l.text)
 }
 
 func (l *Lexer) skipWhiteSpace() {
 	for isWhiteSpace(l.ch) {
-		l.incrementLinePos()
 		l.readChar()
 	}
 }
-func (l *Lexer) incrementLinePos() {
-	if l.ch == '\n' || l.ch == '\r' {
-		l.CurrentLineIdx += 1
+
+// skipTrivia skips whitespace and comments. Comments are kept aside rather
+// than returned as tokens, see Comments.
+func (l *Lexer) skipTrivia() {
+	for {
+		l.skipWhiteSpace()
+		if l.ch != '/' || (l.peekChar() != '/' && l.peekChar() != '*') {
+			return
+		}
+		l.startToken()
+		if l.peekChar() == '/' {
+			l.readLineComment()
+		} else {
+			l.readBlockComment()
+		}
+		l.comments = append(l.comments, token.Token{
+			Type:    token.COMMENT,
+			Literal: string(l.text),
+			Pos:     l.start,
+			End:     l.currentPosition(),
+		})
 	}
 }
 
-func (l *Lexer) readNumber() string {
-	position := l.position
+func (l *Lexer) readLineComment() {
+	for l.ch != '\n' && !l.atEOF() {
+		l.readChar()
+	}
+}
+
+// readBlockComment reads a /* */ comment. Block comments nest, so that code
+// containing comments can itself be commented out.
+func (l *Lexer) readBlockComment() {
+	depth := 0
+	for {
+		switch {
+		case l.atEOF():
+			l.errorf(diag.UnterminatedComment, l.start, "unterminated block comment")
+			return
+		case l.ch == '/' && l.peekChar() == '*':
+			depth += 1
+			l.readChar()
+		case l.ch == '*' && l.peekChar() == '/':
+			depth -= 1
+			l.readChar()
+			if depth == 0 {
+				l.readChar()
+				return
+			}
+		}
+		l.readChar()
+	}
+}
+
+var baseNames = map[int]string{
+	2:  "binary",
+	8:  "octal",
+	10: "decimal",
+	16: "hexadecimal",
+}
+
+// readNumber reads an integer or float literal. Integers may have a 0x, 0o or
+// 0b base prefix, floats a fraction and/or an exponent, and digits may be
+// separated by underscores: 1_000, 0xff_ff, 6.022e23. Malformed literals are
+// reported and returned as ILLEGAL.
+func (l *Lexer) readNumber() (token.TokenType, string) {
+	tokenType := token.TokenType(token.INT)
+	problem := ""
+
+	base := 10
+	if l.ch == '0' {
+		switch l.peekChar() {
+		case 'x', 'X':
+			base = 16
+		case 'o', 'O':
+			base = 8
+		case 'b', 'B':
+			base = 2
+		}
+	}
 
-	for isNumber(l.ch) {
+	if base != 10 {
 		l.readChar()
+		l.readChar()
+		digits, ok := l.readDigits(base, true)
+		if digits == 0 {
+			problem = fmt.Sprintf("%s literal has no digits", baseNames[base])
+		} else if !ok {
+			problem = "'_' must separate successive digits"
+		}
+	} else {
+		_, ok := l.readDigits(10, false)
+		if l.ch == '.' && isNumber(l.peekChar()) {
+			tokenType = token.FLOAT
+			l.readChar()
+			_, fractionOk := l.readDigits(10, false)
+			ok = ok && fractionOk
+		}
+		if l.ch == 'e' || l.ch == 'E' {
+			tokenType = token.FLOAT
+			l.readChar()
+			if l.ch == '+' || l.ch == '-' {
+				l.readChar()
+			}
+			digits, exponentOk := l.readDigits(10, false)
+			ok = ok && exponentOk
+			if digits == 0 {
+				problem = "exponent has no digits"
+			}
+		}
+		if !ok && problem == "" {
+			problem = "'_' must separate successive digits"
+		}
+	}
+
+	if isNumber(l.ch) || isLetter(l.ch) {
+		if problem == "" {
+			if isNumber(l.ch) {
+				problem = fmt.Sprintf("invalid digit %q in %s literal", l.ch, baseNames[base])
+			} else {
+				problem = fmt.Sprintf("invalid character %q in %s literal", l.ch, baseNames[base])
+			}
+		}
+		for isNumber(l.ch) || isLetter(l.ch) {
+			l.readChar()
+		}
 	}
 
-	return l.input[position:l.position]
+	literal := string(l.text)
+	if problem != "" {
+		l.errorf(diag.MalformedNumber, l.start, "malformed number %s: %s", literal, problem)
+		return token.ILLEGAL, literal
+	}
+	return tokenType, literal
 }
 
-func (l *Lexer) peekChar() byte {
-	if l.readPosition >= len(l.input) {
+// readDigits reads digits of the given base and underscores separating them.
+// It returns the number of digits read and whether all underscores were
+// placed between two digits, or directly after a base prefix.
+func (l *Lexer) readDigits(base int, afterPrefix bool) (int, bool) {
+	digits := 0
+	ok := true
+	prevDigit := afterPrefix
+	for isDigit(l.ch, base) || l.ch == '_' {
+		if l.ch == '_' {
+			ok = ok && prevDigit
+			prevDigit = false
+		} else {
+			digits += 1
+			prevDigit = true
+		}
+		l.readChar()
+	}
+	if l.text[len(l.text)-1] == '_' {
+		ok = false
+	}
+	return digits, ok
+}
+
+func (l *Lexer) peekChar() rune {
+	buf, _ := l.peekBytes()
+	if len(buf) == 0 {
 		return 0
 	}
-	return l.input[l.readPosition]
+	ch, _ := utf8.DecodeRune(buf)
+	return ch
+}
+
+// readString reads a double quoted string and returns its contents with
+// escape sequences decoded.
+func (l *Lexer) readString() string {
+	var out strings.Builder
+	l.readChar() // the opening "
+	for {
+		switch {
+		case l.atEOF():
+			l.errorf(diag.UnterminatedString, l.start, "unterminated string literal")
+			return out.String()
+		case l.ch == '"':
+			l.readChar()
+			return out.String()
+		case l.ch == '\\':
+			l.readEscape(&out)
+		default:
+			out.WriteRune(l.ch)
+			l.readChar()
+		}
+	}
+}
+
+var escapes = map[rune]rune{
+	'n':  '\n',
+	't':  '\t',
+	'r':  '\r',
+	'"':  '"',
+	'\\': '\\',
+}
+
+// readEscape decodes the escape sequence starting at the current backslash.
+// Invalid escapes are reported and left out of the string.
+func (l *Lexer) readEscape(out *strings.Builder) {
+	from := l.currentPosition()
+	escape := len(l.text) // index of the backslash in text
+	l.readChar()          // the backslash
+	if l.atEOF() {
+		return
+	}
+	if ch, ok := escapes[l.ch]; ok {
+		out.WriteRune(ch)
+		l.readChar()
+		return
+	}
+	if l.ch != 'u' {
+		l.readChar()
+		l.errorf(diag.InvalidEscape, from, "unknown escape sequence %q", l.text[escape:])
+		return
+	}
+
+	l.readChar()
+	if l.ch != '{' {
+		l.errorf(diag.InvalidEscape, from, "expected { after \\u")
+		return
+	}
+	l.readChar()
+	digits := len(l.text)
+	for isHexDigit(l.ch) {
+		l.readChar()
+	}
+	hex := string(l.text[digits:])
+	if l.ch != '}' {
+		l.errorf(diag.InvalidEscape, from, "expected } to close \\u{%s", hex)
+		return
+	}
+	l.readChar()
+
+	code, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil || len(hex) > 6 || !utf8.ValidRune(rune(code)) {
+		l.errorf(diag.InvalidEscape, from, "invalid unicode code point \\u{%s}", hex)
+		return
+	}
+	out.WriteRune(rune(code))
+}
+
+func (l *Lexer) atEOF() bool {
+	return l.eof
 }
@@ -1,7 +1,12 @@
 package lexer
 
 import (
+	"errors"
+	"io"
+	"slices"
+	"strings"
 	"testing"
+	"testing/iotest"
 
 	"ljos.app/interpreter/token"
 )
@@ -106,7 +111,7 @@ func TestNextTokenSimpleFn(t *testing.T) {
 
 func TestNextTokenMoreSymbolsAndWhiteSpace(t *testing.T) {
 	input := `=+(){}[],;.*
-  !-/*5;
+  !-/ *5;
   5 < 10 > 5;
   `
 	tests := []expectedToken{
@@ -238,3 +243,518 @@ func TestSimpleIfElseTrueFalse(t *testing.T) {
 	}
 	runTestNextToken(input, tests, t)
 }
+
+func TestTokenPositions(t *testing.T) {
+	input := "let x = 10;\n  x >= 5\r\n@"
+	tests := []struct {
+		expectedType token.TokenType
+		pos          token.Position
+		end          token.Position
+	}{
+		{token.LET, token.Position{File: "test.hua", Line: 1, Column: 1, Offset: 0}, token.Position{File: "test.hua", Line: 1, Column: 4, Offset: 3}},
+		{token.IDENTIFIER, token.Position{File: "test.hua", Line: 1, Column: 5, Offset: 4}, token.Position{File: "test.hua", Line: 1, Column: 6, Offset: 5}},
+		{token.ASSIGN, token.Position{File: "test.hua", Line: 1, Column: 7, Offset: 6}, token.Position{File: "test.hua", Line: 1, Column: 8, Offset: 7}},
+		{token.INT, token.Position{File: "test.hua", Line: 1, Column: 9, Offset: 8}, token.Position{File: "test.hua", Line: 1, Column: 11, Offset: 10}},
+		{token.SEMICOLON, token.Position{File: "test.hua", Line: 1, Column: 11, Offset: 10}, token.Position{File: "test.hua", Line: 1, Column: 12, Offset: 11}},
+		{token.IDENTIFIER, token.Position{File: "test.hua", Line: 2, Column: 3, Offset: 14}, token.Position{File: "test.hua", Line: 2, Column: 4, Offset: 15}},
+		{token.GRTR_THAN_EQ, token.Position{File: "test.hua", Line: 2, Column: 5, Offset: 16}, token.Position{File: "test.hua", Line: 2, Column: 7, Offset: 18}},
+		{token.INT, token.Position{File: "test.hua", Line: 2, Column: 8, Offset: 19}, token.Position{File: "test.hua", Line: 2, Column: 9, Offset: 20}},
+		{token.ILLEGAL, token.Position{File: "test.hua", Line: 3, Column: 1, Offset: 22}, token.Position{File: "test.hua", Line: 3, Column: 2, Offset: 23}},
+		{token.EOF, token.Position{File: "test.hua", Line: 3, Column: 2, Offset: 23}, token.Position{File: "test.hua", Line: 3, Column: 2, Offset: 23}},
+	}
+
+	lexer := NewFile("test.hua", input)
+	for i, tt := range tests {
+		tok := lexer.NextToken()
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q", i, tt.expectedType, tok.Type)
+		}
+		if tok.Pos != tt.pos {
+			t.Errorf("tests[%d] - pos wrong. expected=%+v, got=%+v", i, tt.pos, tok.Pos)
+		}
+		if tok.End != tt.end {
+			t.Errorf("tests[%d] - end wrong. expected=%+v, got=%+v", i, tt.end, tok.End)
+		}
+	}
+}
+
+func TestStrings(t *testing.T) {
+	input := `"foobar" "foo bar" "" "line\nbreak\ttab" "say \"hi\"" "back\\slash" "\u{48}\u{e5}\u{1F600}" "größe"`
+	tests := []expectedToken{
+		{token.STRING, "foobar"},
+		{token.STRING, "foo bar"},
+		{token.STRING, ""},
+		{token.STRING, "line\nbreak\ttab"},
+		{token.STRING, `say "hi"`},
+		{token.STRING, `back\slash`},
+		{token.STRING, "Hå😀"},
+		{token.STRING, "größe"},
+		{token.EOF, ""},
+	}
+	runTestNextToken(input, tests, t)
+}
+
+func TestStringErrors(t *testing.T) {
+	tests := []struct {
+		input           string
+		expectedLiteral string
+		expectedError   string
+	}{
+		{`"abc`, "abc", `1:1: error[L0002]: unterminated string literal`},
+		{`"a\qb"`, "ab", `1:3: error[L0003]: unknown escape sequence "\\q"`},
+		{`"\u41"`, "41", `1:2: error[L0003]: expected { after \u`},
+		{`"\u{41"`, "", `1:2: error[L0003]: expected } to close \u{41`},
+		{`"\u{110000}"`, "", `1:2: error[L0003]: invalid unicode code point \u{110000}`},
+		{`"\u{}"`, "", `1:2: error[L0003]: invalid unicode code point \u{}`},
+	}
+
+	for _, tt := range tests {
+		l := New(tt.input)
+		tok := l.NextToken()
+		if tok.Type != token.STRING || tok.Literal != tt.expectedLiteral {
+			t.Errorf("input %s: wrong token. expected=(STRING, %q), got=(%s, %q)",
+				tt.input, tt.expectedLiteral, tok.Type, tok.Literal)
+		}
+		errors := l.Errors()
+		if len(errors) == 0 {
+			t.Errorf("input %s: expected an error", tt.input)
+			continue
+		}
+		if errors[0].Error() != tt.expectedError {
+			t.Errorf("input %s: wrong error. expected=%q, got=%q", tt.input, tt.expectedError, errors[0].Error())
+		}
+	}
+}
+
+func TestComments(t *testing.T) {
+	input := `// leading comment
+let x = 5; // trailing comment
+/* block
+   comment */ let y = x / 2;
+/* outer /* nested */ still outer */
+x /= 2;
+//`
+	tests := []expectedToken{
+		{token.LET, "let"},
+		{token.IDENTIFIER, "x"},
+		{token.ASSIGN, "="},
+		{token.INT, "5"},
+		{token.SEMICOLON, ";"},
+		{token.LET, "let"},
+		{token.IDENTIFIER, "y"},
+		{token.ASSIGN, "="},
+		{token.IDENTIFIER, "x"},
+		{token.SLASH, "/"},
+		{token.INT, "2"},
+		{token.SEMICOLON, ";"},
+		{token.IDENTIFIER, "x"},
+		{token.DIV_ASSIGN, "/="},
+		{token.INT, "2"},
+		{token.SEMICOLON, ";"},
+		{token.EOF, ""},
+	}
+	runTestNextToken(input, tests, t)
+
+	l := New(input)
+	for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+	}
+	expectedComments := []struct {
+		literal string
+		pos     string
+	}{
+		{"// leading comment", "1:1"},
+		{"// trailing comment", "2:12"},
+		{"/* block\n   comment */", "3:1"},
+		{"/* outer /* nested */ still outer */", "5:1"},
+		{"//", "7:1"},
+	}
+	comments := l.Comments()
+	if len(comments) != len(expectedComments) {
+		t.Fatalf("expected %d comments, got %d: %v", len(expectedComments), len(comments), comments)
+	}
+	for i, expected := range expectedComments {
+		if comments[i].Type != token.COMMENT || comments[i].Literal != expected.literal {
+			t.Errorf("comments[%d] wrong. expected=%q, got=(%s, %q)", i, expected.literal, comments[i].Type, comments[i].Literal)
+		}
+		if comments[i].Pos.String() != expected.pos {
+			t.Errorf("comments[%d] pos wrong. expected=%s, got=%s", i, expected.pos, comments[i].Pos)
+		}
+	}
+	if len(l.Errors()) != 0 {
+		t.Errorf("expected no errors, got %v", l.Errors())
+	}
+}
+
+func TestUnterminatedBlockComment(t *testing.T) {
+	l := New("let x = 1; /* outer /* inner */")
+	for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+	}
+	errors := l.Errors()
+	if len(errors) != 1 {
+		t.Fatalf("expected 1 error, got %d", len(errors))
+	}
+	expected := "1:12: error[L0004]: unterminated block comment"
+	if errors[0].Error() != expected {
+		t.Errorf("wrong error. expected=%q, got=%q", expected, errors[0].Error())
+	}
+}
+
+func TestNumbers(t *testing.T) {
+	input := `0 42 1_000_000 0xff 0XFF_FF 0b1010 0o17 0x_ff 3.14 0.5 1e9 2.5E-3 1_000.000_1 6e+2 1.foo 007`
+	tests := []expectedToken{
+		{token.INT, "0"},
+		{token.INT, "42"},
+		{token.INT, "1_000_000"},
+		{token.INT, "0xff"},
+		{token.INT, "0XFF_FF"},
+		{token.INT, "0b1010"},
+		{token.INT, "0o17"},
+		{token.INT, "0x_ff"},
+		{token.FLOAT, "3.14"},
+		{token.FLOAT, "0.5"},
+		{token.FLOAT, "1e9"},
+		{token.FLOAT, "2.5E-3"},
+		{token.FLOAT, "1_000.000_1"},
+		{token.FLOAT, "6e+2"},
+		{token.INT, "1"},
+		{token.DOT, "."},
+		{token.IDENTIFIER, "foo"},
+		{token.INT, "007"},
+		{token.EOF, ""},
+	}
+	runTestNextToken(input, tests, t)
+}
+
+func TestMalformedNumbers(t *testing.T) {
+	tests := []struct {
+		input         string
+		expectedError string
+	}{
+		{"0x", `1:1: error[L0005]: malformed number 0x: hexadecimal literal has no digits`},
+		{"0b102", `1:1: error[L0005]: malformed number 0b102: invalid digit '2' in binary literal`},
+		{"0o8", `1:1: error[L0005]: malformed number 0o8: octal literal has no digits`},
+		{"0xfg", `1:1: error[L0005]: malformed number 0xfg: invalid character 'g' in hexadecimal literal`},
+		{"123abc", `1:1: error[L0005]: malformed number 123abc: invalid character 'a' in decimal literal`},
+		{"1__000", `1:1: error[L0005]: malformed number 1__000: '_' must separate successive digits`},
+		{"1000_", `1:1: error[L0005]: malformed number 1000_: '_' must separate successive digits`},
+		{"1_.5", `1:1: error[L0005]: malformed number 1_.5: '_' must separate successive digits`},
+		{"1e", `1:1: error[L0005]: malformed number 1e: exponent has no digits`},
+		{"2.5e+", `1:1: error[L0005]: malformed number 2.5e+: exponent has no digits`},
+	}
+
+	for _, tt := range tests {
+		l := New(tt.input)
+		tok := l.NextToken()
+		if tok.Type != token.ILLEGAL {
+			t.Errorf("input %s: expected ILLEGAL token, got %s(%q)", tt.input, tok.Type, tok.Literal)
+		}
+		errors := l.Errors()
+		if len(errors) != 1 {
+			t.Errorf("input %s: expected 1 error, got %d: %v", tt.input, len(errors), errors)
+			continue
+		}
+		if errors[0].Error() != tt.expectedError {
+			t.Errorf("input %s: wrong error. expected=%q, got=%q", tt.input, tt.expectedError, errors[0].Error())
+		}
+	}
+}
+
+func TestUnicodeIdentifiers(t *testing.T) {
+	input := `let größe = "🙂"; let 名前 = größe; πr`
+	tests := []expectedToken{
+		{token.LET, "let"},
+		{token.IDENTIFIER, "größe"},
+		{token.ASSIGN, "="},
+		{token.STRING, "🙂"},
+		{token.SEMICOLON, ";"},
+		{token.LET, "let"},
+		{token.IDENTIFIER, "名前"},
+		{token.ASSIGN, "="},
+		{token.IDENTIFIER, "größe"},
+		{token.SEMICOLON, ";"},
+		{token.IDENTIFIER, "πr"},
+		{token.EOF, ""},
+	}
+	runTestNextToken(input, tests, t)
+}
+
+func TestRuneColumns(t *testing.T) {
+	input := "let æøå = \"🙂🙂\" + x;\n  ø"
+	expected := []struct {
+		literal string
+		pos     token.Position
+	}{
+		{"let", token.Position{Line: 1, Column: 1, Offset: 0}},
+		{"æøå", token.Position{Line: 1, Column: 5, Offset: 4}},
+		{"=", token.Position{Line: 1, Column: 9, Offset: 11}},
+		{"🙂🙂", token.Position{Line: 1, Column: 11, Offset: 13}},
+		{"+", token.Position{Line: 1, Column: 16, Offset: 24}},
+		{"x", token.Position{Line: 1, Column: 18, Offset: 26}},
+		{";", token.Position{Line: 1, Column: 19, Offset: 27}},
+		{"ø", token.Position{Line: 2, Column: 3, Offset: 31}},
+	}
+
+	l := New(input)
+	for i, tt := range expected {
+		tok := l.NextToken()
+		if tok.Literal != tt.literal {
+			t.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q", i, tt.literal, tok.Literal)
+		}
+		if tok.Pos != tt.pos {
+			t.Errorf("tests[%d] - pos wrong. expected=%+v, got=%+v", i, tt.pos, tok.Pos)
+		}
+	}
+	if tok := l.NextToken(); tok.Type != token.EOF || tok.Pos.Column != 4 {
+		t.Errorf("expected EOF at column 4, got %s at %+v", tok.Type, tok.Pos)
+	}
+}
+
+func TestInvalidUTF8(t *testing.T) {
+	input := "let x = \xff;\n\"a\xc3b\""
+
+	l := New(input)
+	tests := []expectedToken{
+		{token.LET, "let"},
+		{token.IDENTIFIER, "x"},
+		{token.ASSIGN, "="},
+		{token.ILLEGAL, "\xff"},
+		{token.SEMICOLON, ";"},
+		{token.STRING, "a�b"},
+		{token.EOF, ""},
+	}
+	for i, tt := range tests {
+		tok := l.NextToken()
+		if tok.Type != tt.expectedType || tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - token wrong. expected=(%q, %q), got=(%q, %q)",
+				i, tt.expectedType, tt.expectedLiteral, tok.Type, tok.Literal)
+		}
+	}
+
+	expectedErrors := []string{
+		`1:9: error[L0006]: invalid UTF-8 encoding "\xff"`,
+		`2:3: error[L0006]: invalid UTF-8 encoding "\xc3"`,
+	}
+	errors := l.Errors()
+	if len(errors) != len(expectedErrors) {
+		t.Fatalf("expected %d errors, got %d: %v", len(expectedErrors), len(errors), errors)
+	}
+	for i, expected := range expectedErrors {
+		if errors[i].Error() != expected {
+			t.Errorf("errors[%d] wrong. expected=%q, got=%q", i, expected, errors[i].Error())
+		}
+	}
+}
+
+func TestIdentifiersWithDigits(t *testing.T) {
+	input := `let x1 = v2alpha + _3; let år2024 = x١; lets iffy fn2`
+	tests := []expectedToken{
+		{token.LET, "let"},
+		{token.IDENTIFIER, "x1"},
+		{token.ASSIGN, "="},
+		{token.IDENTIFIER, "v2alpha"},
+		{token.PLUS, "+"},
+		{token.IDENTIFIER, "_3"},
+		{token.SEMICOLON, ";"},
+		{token.LET, "let"},
+		{token.IDENTIFIER, "år2024"},
+		{token.ASSIGN, "="},
+		{token.IDENTIFIER, "x١"},
+		{token.SEMICOLON, ";"},
+		{token.IDENTIFIER, "lets"},
+		{token.IDENTIFIER, "iffy"},
+		{token.IDENTIFIER, "fn2"},
+		{token.EOF, ""},
+	}
+	runTestNextToken(input, tests, t)
+}
+
+func TestAssignmentOperators(t *testing.T) {
+	input := `x = 1; x += 2; x -= 3; x *= 4; x /= 5; x %= 6;`
+	tests := []expectedToken{
+		{token.IDENTIFIER, "x"},
+		{token.ASSIGN, "="},
+		{token.INT, "1"},
+		{token.SEMICOLON, ";"},
+		{token.IDENTIFIER, "x"},
+		{token.PLUS_ASSIGN, "+="},
+		{token.INT, "2"},
+		{token.SEMICOLON, ";"},
+		{token.IDENTIFIER, "x"},
+		{token.MINUS_ASSIGN, "-="},
+		{token.INT, "3"},
+		{token.SEMICOLON, ";"},
+		{token.IDENTIFIER, "x"},
+		{token.MUL_ASSIGN, "*="},
+		{token.INT, "4"},
+		{token.SEMICOLON, ";"},
+		{token.IDENTIFIER, "x"},
+		{token.DIV_ASSIGN, "/="},
+		{token.INT, "5"},
+		{token.SEMICOLON, ";"},
+		{token.IDENTIFIER, "x"},
+		{token.MOD_ASSIGN, "%="},
+		{token.INT, "6"},
+		{token.SEMICOLON, ";"},
+		{token.EOF, ""},
+	}
+	runTestNextToken(input, tests, t)
+}
+
+func TestLongestMatchOperators(t *testing.T) {
+	input := `a && b || c % d & e | f ^ g << h >> i ** j
+**= <<= >>= &= |= ^= <<<= ***`
+	tests := []expectedToken{
+		{token.IDENTIFIER, "a"},
+		{token.LOGICAL_AND, "&&"},
+		{token.IDENTIFIER, "b"},
+		{token.LOGICAL_OR, "||"},
+		{token.IDENTIFIER, "c"},
+		{token.PERCENT, "%"},
+		{token.IDENTIFIER, "d"},
+		{token.AMPERSAND, "&"},
+		{token.IDENTIFIER, "e"},
+		{token.PIPE, "|"},
+		{token.IDENTIFIER, "f"},
+		{token.CARET, "^"},
+		{token.IDENTIFIER, "g"},
+		{token.SHIFT_LEFT, "<<"},
+		{token.IDENTIFIER, "h"},
+		{token.SHIFT_RIGHT, ">>"},
+		{token.IDENTIFIER, "i"},
+		{token.POWER, "**"},
+		{token.IDENTIFIER, "j"},
+		{token.POW_ASSIGN, "**="},
+		{token.SHL_ASSIGN, "<<="},
+		{token.SHR_ASSIGN, ">>="},
+		{token.AND_ASSIGN, "&="},
+		{token.OR_ASSIGN, "|="},
+		{token.XOR_ASSIGN, "^="},
+		{token.SHIFT_LEFT, "<<"},
+		{token.LESS_THAN_EQ, "<="},
+		{token.POWER, "**"},
+		{token.ASTERISK, "*"},
+		{token.EOF, ""},
+	}
+	runTestNextToken(input, tests, t)
+}
+
+func TestNewReader(t *testing.T) {
+	input := "let æøå = fn(x) { x ** 2 }; // square\n" +
+		"/* outer /* inner */ */ \"a\\tb\\u{1F642}\\q\" 0x_ff 1_000 6.022e23 1__0 0b12\n" +
+		"\xff y <<= 3 @ \"open"
+
+	expected := New(input)
+	// read a byte at a time, so runes and tokens span reads
+	l := NewReader(iotest.OneByteReader(strings.NewReader(input)))
+	for i := 0; ; i++ {
+		want, got := expected.NextToken(), l.NextToken()
+		if got != want {
+			t.Fatalf("tokens[%d] wrong. expected=%+v, got=%+v", i, want, got)
+		}
+		if got.Type == token.EOF {
+			break
+		}
+	}
+
+	if len(l.Comments()) != 2 || l.Comments()[1].Literal != "/* outer /* inner */ */" {
+		t.Errorf("wrong comments: %+v", l.Comments())
+	}
+	if len(l.Errors()) != len(expected.Errors()) {
+		t.Fatalf("expected %d errors, got %v", len(expected.Errors()), l.Errors())
+	}
+	for i, err := range l.Errors() {
+		if err.Error() != expected.Errors()[i].Error() {
+			t.Errorf("errors[%d] wrong. expected=%q, got=%q", i, expected.Errors()[i].Error(), err.Error())
+		}
+	}
+}
+
+func TestNewReaderStreams(t *testing.T) {
+	r, w := io.Pipe()
+	defer w.Close()
+	go w.Write([]byte("let x = 1;\n"))
+
+	// the tokens of the first line are available before the input ends
+	l := NewReader(r)
+	for _, expected := range []token.TokenType{token.LET, token.IDENTIFIER, token.ASSIGN, token.INT, token.SEMICOLON} {
+		if tok := l.NextToken(); tok.Type != expected {
+			t.Fatalf("expected %s, got %s", expected, tok.Type)
+		}
+	}
+}
+
+func TestNewReaderError(t *testing.T) {
+	r := io.MultiReader(strings.NewReader("let x"), iotest.ErrReader(errors.New("connection reset")))
+
+	l := NewReader(r)
+	for _, expected := range []token.TokenType{token.LET, token.IDENTIFIER, token.EOF} {
+		if tok := l.NextToken(); tok.Type != expected {
+			t.Fatalf("expected %s, got %s", expected, tok.Type)
+		}
+	}
+	errors := l.Errors()
+	if len(errors) != 1 || errors[0].Error() != "1:6: error[L0007]: reading input failed: connection reset" {
+		t.Errorf("wrong errors: %v", errors)
+	}
+}
+
+func TestAll(t *testing.T) {
+	l := New("let x = 1; @ y")
+
+	var types []token.TokenType
+	for tok := range l.All() {
+		types = append(types, tok.Type)
+		if tok.Type == token.SEMICOLON {
+			break
+		}
+	}
+	expected := []token.TokenType{token.LET, token.IDENTIFIER, token.ASSIGN, token.INT, token.SEMICOLON}
+	if !slices.Equal(types, expected) {
+		t.Fatalf("wrong tokens. expected=%v, got=%v", expected, types)
+	}
+
+	// ranging again continues after the break, and stops before EOF
+	types = nil
+	for tok := range l.All() {
+		types = append(types, tok.Type)
+	}
+	expected = []token.TokenType{token.ILLEGAL, token.IDENTIFIER}
+	if !slices.Equal(types, expected) {
+		t.Fatalf("wrong tokens. expected=%v, got=%v", expected, types)
+	}
+}
+
+func TestTokenize(t *testing.T) {
+	tokens, diagnostics := Tokenize("let x = @1 + 0b2;")
+
+	expected := []expectedToken{
+		{token.LET, "let"},
+		{token.IDENTIFIER, "x"},
+		{token.ASSIGN, "="},
+		{token.INT, "1"},
+		{token.PLUS, "+"},
+		{token.SEMICOLON, ";"},
+	}
+	if len(tokens) != len(expected) {
+		t.Fatalf("expected %d tokens, got %+v", len(expected), tokens)
+	}
+	for i, tt := range expected {
+		if tokens[i].Type != tt.expectedType || tokens[i].Literal != tt.expectedLiteral {
+			t.Errorf("tokens[%d] wrong. expected=(%q, %q), got=(%q, %q)",
+				i, tt.expectedType, tt.expectedLiteral, tokens[i].Type, tokens[i].Literal)
+		}
+	}
+
+	expectedErrors := []string{
+		`1:9: error[L0001]: illegal character "@"`,
+		`1:14: error[L0005]: malformed number 0b2: binary literal has no digits`,
+	}
+	if len(diagnostics) != len(expectedErrors) {
+		t.Fatalf("expected %d diagnostics, got %v", len(expectedErrors), diagnostics)
+	}
+	for i, expected := range expectedErrors {
+		if diagnostics[i].Error() != expected {
+			t.Errorf("diagnostics[%d] wrong. expected=%q, got=%q", i, expected, diagnostics[i].Error())
+		}
+	}
+}
@@ -0,0 +1,431 @@
+// Package lineedit reads lines from a terminal with emacs style editing keys,
+// history and tab completion.
+package lineedit
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"unicode"
+)
+
+// ErrInterrupted is returned by ReadLine when the user presses Ctrl-C.
+var ErrInterrupted = errors.New("interrupted")
+
+// MaxHistory is the number of lines kept in the history.
+const MaxHistory = 1000
+
+const (
+	keyCtrlA     = 1
+	keyCtrlB     = 2
+	keyCtrlC     = 3
+	keyCtrlD     = 4
+	keyCtrlE     = 5
+	keyCtrlF     = 6
+	keyCtrlG     = 7
+	keyBackspace = 8
+	keyTab       = 9
+	keyLineFeed  = 10
+	keyCtrlK     = 11
+	keyCtrlL     = 12
+	keyEnter     = 13
+	keyCtrlN     = 14
+	keyCtrlP     = 16
+	keyCtrlR     = 18
+	keyCtrlU     = 21
+	keyCtrlW     = 23
+	keyEscape    = 27
+	keyDelete    = 127
+
+	// escape sequences are mapped to runes from the private use area
+	keyUp rune = 0xE000 + iota
+	keyDown
+	keyRight
+	keyLeft
+	keyHome
+	keyEnd
+	keyDeleteForward
+	keyUnknown
+)
+
+// Editor reads lines from a terminal. It keeps the history of the lines
+// added with AddHistory across calls to ReadLine.
+type Editor struct {
+	// Complete returns the candidates for completing word, the part of an
+	// identifier up to the cursor. It is called when Tab is pressed.
+	Complete func(word string) []string
+
+	in          *os.File
+	reader      *bufio.Reader
+	out         io.Writer
+	history     []string
+	historyFile string
+}
+
+// New creates an editor reading keys from the terminal in and drawing the
+// line being edited on out.
+func New(in *os.File, out io.Writer) *Editor {
+	return &Editor{in: in, reader: bufio.NewReader(in), out: out}
+}
+
+// ReadLine shows prompt and returns the line entered, without the newline.
+// It returns io.EOF when Ctrl-D is pressed on an empty line and
+// ErrInterrupted when Ctrl-C is pressed.
+func (e *Editor) ReadLine(prompt string) (string, error) {
+	restore, err := makeRaw(e.in.Fd())
+	if err != nil {
+		return "", err
+	}
+	defer restore()
+	return e.edit(prompt)
+}
+
+// AddHistory appends line to the history, skipping empty lines and repeats
+// of the previous line. With a history file set it is appended there too.
+func (e *Editor) AddHistory(line string) error {
+	if strings.TrimSpace(line) == "" {
+		return nil
+	}
+	if len(e.history) > 0 && e.history[len(e.history)-1] == line {
+		return nil
+	}
+	e.history = append(e.history, line)
+	if len(e.history) > MaxHistory {
+		e.history = e.history[len(e.history)-MaxHistory:]
+	}
+	if e.historyFile == "" {
+		return nil
+	}
+	f, err := os.OpenFile(e.historyFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(f, line)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// History returns the lines added so far, oldest first.
+func (e *Editor) History() []string {
+	return e.history
+}
+
+// SetHistoryFile loads the history from path, one line per entry, and
+// appends lines added afterwards to it. A file holding more than MaxHistory
+// entries is trimmed to the newest ones. A missing file is not an error.
+func (e *Editor) SetHistoryFile(path string) error {
+	e.historyFile = path
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		if line != "" {
+			e.history = append(e.history, line)
+		}
+	}
+	if len(e.history) <= MaxHistory {
+		return nil
+	}
+	e.history = e.history[len(e.history)-MaxHistory:]
+	return os.WriteFile(path, []byte(strings.Join(e.history, "\n")+"\n"), 0o600)
+}
+
+// lineState is the line being edited.
+type lineState struct {
+	prompt string
+	buf    []rune
+	pos    int // cursor position in buf
+	// index into the history of the entry shown, len(history) for the line
+	// being typed, which is kept in saved while browsing
+	historyIndex int
+	saved        []rune
+}
+
+// edit runs the key loop, assuming the terminal is in raw mode.
+func (e *Editor) edit(prompt string) (string, error) {
+	s := &lineState{prompt: prompt, historyIndex: len(e.history)}
+	e.refresh(s)
+
+	for {
+		key, err := e.readKey()
+		if err != nil {
+			return "", err
+		}
+
+		switch key {
+		case keyEnter, keyLineFeed:
+			fmt.Fprint(e.out, "\r\n")
+			return string(s.buf), nil
+		case keyCtrlC:
+			fmt.Fprint(e.out, "^C\r\n")
+			return "", ErrInterrupted
+		case keyCtrlD:
+			if len(s.buf) == 0 {
+				fmt.Fprint(e.out, "\r\n")
+				return "", io.EOF
+			}
+			s.deleteForward()
+		case keyDeleteForward:
+			s.deleteForward()
+		case keyBackspace, keyDelete:
+			if s.pos > 0 {
+				s.buf = append(s.buf[:s.pos-1], s.buf[s.pos:]...)
+				s.pos--
+			}
+		case keyCtrlA, keyHome:
+			s.pos = 0
+		case keyCtrlE, keyEnd:
+			s.pos = len(s.buf)
+		case keyCtrlB, keyLeft:
+			s.pos = max(s.pos-1, 0)
+		case keyCtrlF, keyRight:
+			s.pos = min(s.pos+1, len(s.buf))
+		case keyCtrlK:
+			s.buf = s.buf[:s.pos]
+		case keyCtrlU:
+			s.buf = append([]rune{}, s.buf[s.pos:]...)
+			s.pos = 0
+		case keyCtrlW:
+			start := s.pos
+			for start > 0 && s.buf[start-1] == ' ' {
+				start--
+			}
+			for start > 0 && s.buf[start-1] != ' ' {
+				start--
+			}
+			s.buf = append(s.buf[:start], s.buf[s.pos:]...)
+			s.pos = start
+		case keyCtrlL:
+			fmt.Fprint(e.out, "\x1b[H\x1b[2J")
+		case keyCtrlP, keyUp:
+			e.browseHistory(s, -1)
+		case keyCtrlN, keyDown:
+			e.browseHistory(s, 1)
+		case keyTab:
+			e.complete(s)
+		case keyCtrlR:
+			if line, accepted, err := e.search(s); err != nil || accepted {
+				return line, err
+			}
+		default:
+			if unicode.IsPrint(key) {
+				s.insert(key)
+			}
+		}
+		e.refresh(s)
+	}
+}
+
+func (s *lineState) insert(r ...rune) {
+	tail := append(r, s.buf[s.pos:]...)
+	s.buf = append(s.buf[:s.pos], tail...)
+	s.pos += len(r)
+}
+
+func (s *lineState) deleteForward() {
+	if s.pos < len(s.buf) {
+		s.buf = append(s.buf[:s.pos], s.buf[s.pos+1:]...)
+	}
+}
+
+// refresh redraws the prompt and line and places the cursor.
+func (e *Editor) refresh(s *lineState) {
+	fmt.Fprintf(e.out, "\r%s%s\x1b[K\r", s.prompt, string(s.buf))
+	if col := len([]rune(s.prompt)) + s.pos; col > 0 {
+		fmt.Fprintf(e.out, "\x1b[%dC", col)
+	}
+}
+
+// browseHistory replaces the line with an older (dir -1) or newer (dir 1)
+// history entry. Moving past the newest entry brings back the typed line.
+func (e *Editor) browseHistory(s *lineState, dir int) {
+	next := s.historyIndex + dir
+	if next < 0 || next > len(e.history) {
+		return
+	}
+	if s.historyIndex == len(e.history) {
+		s.saved = s.buf
+	}
+	s.historyIndex = next
+	if next == len(e.history) {
+		s.buf = s.saved
+	} else {
+		s.buf = []rune(e.history[next])
+	}
+	s.pos = len(s.buf)
+}
+
+// complete extends the word before the cursor to the longest common prefix
+// of its completions. If that adds nothing the candidates are listed.
+func (e *Editor) complete(s *lineState) {
+	if e.Complete == nil {
+		return
+	}
+	start := s.pos
+	for start > 0 && isWordChar(s.buf[start-1]) {
+		start--
+	}
+	word := string(s.buf[start:s.pos])
+	candidates := e.Complete(word)
+	if len(candidates) == 0 {
+		return
+	}
+
+	prefix := []rune(candidates[0])
+	for _, c := range candidates[1:] {
+		prefix = commonPrefix(prefix, []rune(c))
+	}
+	if len(prefix) > s.pos-start && strings.HasPrefix(string(prefix), word) {
+		s.insert(prefix[s.pos-start:]...)
+		return
+	}
+	if len(candidates) > 1 {
+		fmt.Fprintf(e.out, "\r\n%s\r\n", strings.Join(candidates, "  "))
+	}
+}
+
+func commonPrefix(a, b []rune) []rune {
+	n := 0
+	for n < len(a) && n < len(b) && a[n] == b[n] {
+		n++
+	}
+	return a[:n]
+}
+
+func isWordChar(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// search runs an incremental reverse search through the history started
+// with Ctrl-R. Typing narrows the search, Ctrl-R again finds an older
+// match, Enter runs the match and Ctrl-G cancels. Any other key leaves the
+// match on the line for editing. accepted is set when Enter was pressed.
+func (e *Editor) search(s *lineState) (line string, accepted bool, err error) {
+	var query []rune
+	index := len(e.history)
+	match := ""
+	failing := false
+
+	find := func(from int) {
+		for i := from; i >= 0; i-- {
+			if strings.Contains(e.history[i], string(query)) {
+				index, match, failing = i, e.history[i], false
+				return
+			}
+		}
+		failing = true
+	}
+
+	for {
+		status := "reverse-i-search"
+		if failing {
+			status = "failing " + status
+		}
+		fmt.Fprintf(e.out, "\r(%s)'%s': %s\x1b[K", status, string(query), match)
+		key, err := e.readKey()
+		if err != nil {
+			return "", false, err
+		}
+		switch key {
+		case keyCtrlR:
+			find(index - 1)
+		case keyBackspace, keyDelete:
+			if len(query) > 0 {
+				query = query[:len(query)-1]
+				index, match = len(e.history), ""
+				find(index - 1)
+			}
+		case keyEnter, keyLineFeed:
+			fmt.Fprintf(e.out, "\r%s%s\x1b[K\r\n", s.prompt, match)
+			return match, true, nil
+		case keyCtrlG, keyCtrlC:
+			return "", false, nil
+		default:
+			if unicode.IsPrint(key) {
+				query = append(query, key)
+				find(min(index, len(e.history)-1))
+				continue
+			}
+			if match != "" {
+				s.buf = []rune(match)
+				s.pos = len(s.buf)
+			}
+			return "", false, nil
+		}
+	}
+}
+
+// readKey reads a single key press, translating the escape sequences of the
+// arrow, home, end and delete keys. The Esc key on its own is ignored.
+func (e *Editor) readKey() (rune, error) {
+	r, _, err := e.reader.ReadRune()
+	if err != nil || r != keyEscape {
+		return r, err
+	}
+	if e.reader.Buffered() == 0 {
+		// the terminal sends a sequence all at once, so a lone Esc was the
+		// Esc key itself and the next key is not part of it
+		return keyUnknown, nil
+	}
+
+	next, _, err := e.reader.ReadRune()
+	if err != nil {
+		return 0, err
+	}
+	if next != '[' && next != 'O' {
+		return keyUnknown, nil
+	}
+	code, _, err := e.reader.ReadRune()
+	if err != nil {
+		return 0, err
+	}
+	switch code {
+	case 'A':
+		return keyUp, nil
+	case 'B':
+		return keyDown, nil
+	case 'C':
+		return keyRight, nil
+	case 'D':
+		return keyLeft, nil
+	case 'H':
+		return keyHome, nil
+	case 'F':
+		return keyEnd, nil
+	}
+	if code < '0' || code > '9' {
+		return keyUnknown, nil
+	}
+	// ESC [ n ~
+	for {
+		r, _, err := e.reader.ReadRune()
+		if err != nil {
+			return 0, err
+		}
+		if r == '~' {
+			break
+		}
+		if r < '0' || r > '9' {
+			return keyUnknown, nil
+		}
+		code = -1
+	}
+	switch code {
+	case '1', '7':
+		return keyHome, nil
+	case '4', '8':
+		return keyEnd, nil
+	case '3':
+		return keyDeleteForward, nil
+	}
+	return keyUnknown, nil
+}
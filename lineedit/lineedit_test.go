@@ -0,0 +1,249 @@
+package lineedit
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func newTestEditor(keys string) *Editor {
+	return &Editor{reader: bufio.NewReader(strings.NewReader(keys)), out: &bytes.Buffer{}}
+}
+
+func TestEditing(t *testing.T) {
+	tests := []struct {
+		keys     string
+		expected string
+	}{
+		{"let x = 1\r", "let x = 1"},
+		{"hello\n", "hello"},
+		{"héllo\r", "héllo"},
+		{"abc\x7f\x7fd\r", "ad"},
+		{"abc\x08d\r", "abd"},
+		{"bc\x01a\r", "abc"},
+		{"ac\x1b[Db\r", "abc"},
+		{"ac\x02b\x05d\r", "abcd"},
+		{"abc\x1b[H\x1b[C\x1b[Cx\r", "abxc"},
+		{"abc\x1b[1~x\x1b[4~y\r", "xabcy"},
+		{"abc\x01\x1b[3~\r", "bc"},
+		{"abc\x01\x04\r", "bc"},
+		{"hello world\x01\x06\x06\x0b\r", "he"},
+		{"hello world\x02\x02\x15\r", "ld"},
+		{"let foo bar\x17\r", "let foo "},
+		{"a\tb\r", "ab"},
+		{"abc\x1b[Z\r", "abc"},
+	}
+
+	for _, tt := range tests {
+		e := newTestEditor(tt.keys)
+		line, err := e.edit("> ")
+		if err != nil {
+			t.Errorf("keys %q: unexpected error %v", tt.keys, err)
+			continue
+		}
+		if line != tt.expected {
+			t.Errorf("keys %q: expected %q, got %q", tt.keys, tt.expected, line)
+		}
+	}
+}
+
+// keyPresses delivers one key press per Read, like a terminal does.
+type keyPresses []string
+
+func (k *keyPresses) Read(p []byte) (int, error) {
+	if len(*k) == 0 {
+		return 0, io.EOF
+	}
+	n := copy(p, (*k)[0])
+	*k = (*k)[1:]
+	return n, nil
+}
+
+func TestEscapeKey(t *testing.T) {
+	keys := keyPresses{"a", "c", "\x1b", "\x1b[D", "b", "\x1b", "\r"}
+	e := &Editor{reader: bufio.NewReader(&keys), out: &bytes.Buffer{}}
+
+	line, err := e.edit("> ")
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if line != "abc" {
+		t.Errorf("Esc should not swallow the following key, got %q", line)
+	}
+}
+
+func TestControlKeys(t *testing.T) {
+	if _, err := newTestEditor("abc\x03").edit("> "); !errors.Is(err, ErrInterrupted) {
+		t.Errorf("Ctrl-C should interrupt, got %v", err)
+	}
+	if _, err := newTestEditor("\x04").edit("> "); err != io.EOF {
+		t.Errorf("Ctrl-D on an empty line should give io.EOF, got %v", err)
+	}
+	if _, err := newTestEditor("abc").edit("> "); err != io.EOF {
+		t.Errorf("end of input should give io.EOF, got %v", err)
+	}
+}
+
+func TestHistory(t *testing.T) {
+	e := newTestEditor("\x1b[A\x1b[A\r" + "\x10\x10\x0e\r" + "new\x1b[A\x1b[B\r" + "\x1b[A\x1b[A\x1b[A\x1b[A\r")
+	for _, line := range []string{"first", "second", "second", "  "} {
+		e.AddHistory(line)
+	}
+	if !reflect.DeepEqual(e.History(), []string{"first", "second"}) {
+		t.Fatalf("empty lines and repeats should be skipped, got %q", e.History())
+	}
+
+	expected := []string{"first", "second", "new", "first"}
+	for _, want := range expected {
+		line, err := e.edit("> ")
+		if err != nil {
+			t.Fatalf("unexpected error %v", err)
+		}
+		if line != want {
+			t.Errorf("expected %q, got %q", want, line)
+		}
+	}
+}
+
+func TestHistoryFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history")
+	if err := os.WriteFile(path, []byte("one\ntwo\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	e := newTestEditor("")
+	if err := e.SetHistoryFile(path); err != nil {
+		t.Fatalf("SetHistoryFile returned %v", err)
+	}
+	if err := e.AddHistory("three"); err != nil {
+		t.Fatalf("AddHistory returned %v", err)
+	}
+	if !reflect.DeepEqual(e.History(), []string{"one", "two", "three"}) {
+		t.Errorf("wrong history, got %q", e.History())
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "one\ntwo\nthree\n" {
+		t.Errorf("line was not appended to the history file, got %q", data)
+	}
+
+	missing := newTestEditor("")
+	if err := missing.SetHistoryFile(filepath.Join(t.TempDir(), "missing")); err != nil {
+		t.Errorf("a missing history file should not be an error, got %v", err)
+	}
+}
+
+func TestHistoryFileTrimmed(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history")
+	var lines []string
+	for i := 0; i < MaxHistory+10; i++ {
+		lines = append(lines, fmt.Sprintf("line %d", i))
+	}
+	if err := os.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	e := newTestEditor("")
+	if err := e.SetHistoryFile(path); err != nil {
+		t.Fatalf("SetHistoryFile returned %v", err)
+	}
+	if !reflect.DeepEqual(e.History(), lines[10:]) {
+		t.Errorf("history should keep the newest %d lines, got %d starting with %q",
+			MaxHistory, len(e.History()), e.History()[0])
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != strings.Join(lines[10:], "\n")+"\n" {
+		t.Errorf("history file was not trimmed, it has %d lines", strings.Count(string(data), "\n"))
+	}
+}
+
+func TestReverseSearch(t *testing.T) {
+	tests := []struct {
+		keys     string
+		expected string
+	}{
+		{"\x12fn\r", "let g = fn(y) { y }"},
+		{"\x12fn\x12\r", "let f = fn(x) { x }"},
+		{"\x12fn\x12\x12\r", "let f = fn(x) { x }"},
+		{"\x12len\r", "len(xs)"},
+		{"\x12f = \x05!\r", "let f = fn(x) { x }!"},
+		{"abc\x12zzz\x07\r", "abc"},
+		{"\x12fnx\x7f\r", "let g = fn(y) { y }"},
+	}
+
+	for _, tt := range tests {
+		e := newTestEditor(tt.keys)
+		for _, line := range []string{"let f = fn(x) { x }", "len(xs)", "let g = fn(y) { y }"} {
+			e.AddHistory(line)
+		}
+		line, err := e.edit("> ")
+		if err != nil {
+			t.Errorf("keys %q: unexpected error %v", tt.keys, err)
+			continue
+		}
+		if line != tt.expected {
+			t.Errorf("keys %q: expected %q, got %q", tt.keys, tt.expected, line)
+		}
+	}
+}
+
+func TestCompletion(t *testing.T) {
+	words := []string{"let", "len", "length", "return", "héllo", "hèllo"}
+	complete := func(word string) []string {
+		var candidates []string
+		for _, w := range words {
+			if strings.HasPrefix(w, word) {
+				candidates = append(candidates, w)
+			}
+		}
+		return candidates
+	}
+
+	tests := []struct {
+		keys     string
+		expected string
+	}{
+		{"ret\t\r", "return"},
+		{"le\t\r", "le"},
+		{"lengt\t\r", "length"},
+		{"x = re\t\r", "x = return"},
+		{"h\t\r", "h"},
+		{"hé\t\r", "héllo"},
+		{"zz\t\r", "zz"},
+		{"ret x\x02\x02\t\r", "return x"},
+	}
+
+	for _, tt := range tests {
+		e := newTestEditor(tt.keys)
+		e.Complete = complete
+		line, err := e.edit("> ")
+		if err != nil {
+			t.Errorf("keys %q: unexpected error %v", tt.keys, err)
+			continue
+		}
+		if line != tt.expected {
+			t.Errorf("keys %q: expected %q, got %q", tt.keys, tt.expected, line)
+		}
+	}
+
+	e := newTestEditor("le\t\r")
+	e.Complete = complete
+	e.edit("> ")
+	if out := e.out.(*bytes.Buffer).String(); !strings.Contains(out, "let  len  length") {
+		t.Errorf("ambiguous completions should be listed, got %q", out)
+	}
+}
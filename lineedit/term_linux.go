@@ -0,0 +1,11 @@
+//go:build linux
+
+package lineedit
+
+import "syscall"
+
+// ioctl requests reading and writing the terminal settings.
+const (
+	ioctlGetTermios = syscall.TCGETS
+	ioctlSetTermios = syscall.TCSETS
+)
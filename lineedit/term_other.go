@@ -0,0 +1,18 @@
+//go:build !darwin && !dragonfly && !freebsd && !linux && !netbsd && !openbsd
+
+package lineedit
+
+import (
+	"errors"
+	"os"
+)
+
+// IsTerminal always reports false on platforms without raw mode support, so
+// that callers fall back to reading plain lines.
+func IsTerminal(f *os.File) bool {
+	return false
+}
+
+func makeRaw(fd uintptr) (func(), error) {
+	return nil, errors.New("lineedit: raw mode is not supported on this platform")
+}
@@ -2,17 +2,189 @@ package main
 
 import (
 	"fmt"
+	"io"
 	"os"
 	"os/user"
 
+	"ljos.app/interpreter/ast"
+	"ljos.app/interpreter/checker"
+	"ljos.app/interpreter/diag"
+	"ljos.app/interpreter/evaluator"
+	"ljos.app/interpreter/lexer"
+	"ljos.app/interpreter/object"
+	"ljos.app/interpreter/parser"
 	"ljos.app/interpreter/repl"
 )
 
+// version is set at build time with -ldflags "-X main.version=...".
+var version = "dev"
+
+const usage = `usage: hua <command> [arguments]
+
+commands:
+  run <file> [args]   run a program, with args bound to the array args
+  check <file>        parse a program and report mistakes without running it
+  tokens <file>       print the tokens of a program
+  ast <file>          print the syntax tree of a program
+  repl                start an interactive session (the default)
+
+A file of - reads the program from stdin.
+
+flags:
+  -h, --help          show this help
+  --version           print the version
+`
+
+// Exit codes of hua.
+const (
+	exitOK    = 0
+	exitError = 1 // the program has errors or failed when run
+	exitUsage = 2 // hua was called with the wrong arguments
+)
+
 func main() {
-	user, err := user.Current()
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+func run(args []string, stdin io.ReadCloser, stdout, stderr io.Writer) int {
+	if len(args) == 0 {
+		return startRepl(stdin, stdout, stderr)
+	}
+
+	switch args[0] {
+	case "-h", "--help", "help":
+		fmt.Fprint(stdout, usage)
+		return exitOK
+	case "--version", "version":
+		fmt.Fprintf(stdout, "hua %s\n", version)
+		return exitOK
+	case "repl":
+		return startRepl(stdin, stdout, stderr)
+	case "run", "check", "tokens", "ast":
+	default:
+		fmt.Fprintf(stderr, "hua: unknown command %q\n\n%s", args[0], usage)
+		return exitUsage
+	}
+
+	if args[0] == "run" && len(args) < 2 {
+		fmt.Fprintln(stderr, "usage: hua run <file> [args]")
+		return exitUsage
+	}
+	if args[0] != "run" && len(args) != 2 {
+		fmt.Fprintf(stderr, "usage: hua %s <file>\n", args[0])
+		return exitUsage
+	}
+	name, src, err := readSource(args[1], stdin)
 	if err != nil {
-		panic(err)
+		fmt.Fprintf(stderr, "hua: %s\n", err)
+		return exitError
+	}
+
+	switch args[0] {
+	case "run":
+		return runFile(name, src, args[2:], stdout, stderr)
+	case "check":
+		return checkFile(name, src, stderr)
+	case "tokens":
+		return printTokens(name, src, stdout, stderr)
+	default:
+		return printAst(name, src, stdout, stderr)
+	}
+}
+
+// readSource reads the program in path, or from stdin if path is -.
+func readSource(path string, stdin io.Reader) (string, string, error) {
+	if path == "-" {
+		src, err := io.ReadAll(stdin)
+		return "<stdin>", string(src), err
+	}
+	src, err := os.ReadFile(path)
+	return path, string(src), err
+}
+
+func startRepl(stdin io.ReadCloser, stdout, stderr io.Writer) int {
+	name := "there"
+	if u, err := user.Current(); err == nil && u.Name != "" {
+		name = u.Name
+	} else if err == nil {
+		name = u.Username
+	}
+	fmt.Fprintf(stdout, "Hello, %s! This is the hualang programming language.\n", name)
+	fmt.Fprintf(stdout, "Type :help for a list of commands.\n")
+	repl.Start(stdin, stdout, stderr)
+	return exitOK
+}
+
+// parse parses src, printing any diagnostics to stderr. It returns nil if
+// there were errors.
+func parse(name, src string, stderr io.Writer) *ast.Program {
+	p := parser.New(lexer.NewFile(name, src))
+	program := p.ParseProgram()
+	if errors := p.Errors(); len(errors) != 0 {
+		diag.NewRenderer(src).Render(stderr, errors...)
+		return nil
+	}
+	return program
+}
+
+// globalEnvironment creates the environment programs are run in, with the
+// command line arguments bound to args.
+func globalEnvironment(stdout io.Writer, args []string) *object.Environment {
+	env := evaluator.NewGlobalEnvironment(stdout)
+	elements := []object.Object{}
+	for _, arg := range args {
+		elements = append(elements, &object.String{Value: arg})
+	}
+	env.Set("args", &object.Array{Elements: elements})
+	return env
+}
+
+func runFile(name, src string, args []string, stdout, stderr io.Writer) int {
+	program := parse(name, src, stderr)
+	if program == nil {
+		return exitError
+	}
+	result := evaluator.Eval(program, globalEnvironment(stdout, args))
+	if err, ok := result.(*object.Error); ok {
+		diag.NewRenderer(src).Render(stderr, err.Diagnostic())
+		return exitError
+	}
+	return exitOK
+}
+
+func checkFile(name, src string, stderr io.Writer) int {
+	program := parse(name, src, stderr)
+	if program == nil {
+		return exitError
+	}
+	predeclared := globalEnvironment(io.Discard, nil).Names()
+	if errors := checker.Check(program, predeclared); len(errors) != 0 {
+		diag.NewRenderer(src).Render(stderr, errors...)
+		return exitError
+	}
+	return exitOK
+}
+
+func printTokens(name, src string, stdout, stderr io.Writer) int {
+	l := lexer.NewFile(name, src)
+	for tok := range l.All() {
+		fmt.Fprintf(stdout, "%d:%d\t%s\t%q\n", tok.Pos.Line, tok.Pos.Column, tok.Type, tok.Literal)
+	}
+	if errors := l.Errors(); len(errors) != 0 {
+		diag.NewRenderer(src).Render(stderr, errors...)
+		return exitError
+	}
+	return exitOK
+}
+
+func printAst(name, src string, stdout, stderr io.Writer) int {
+	program := parse(name, src, stderr)
+	if program == nil {
+		return exitError
+	}
+	if err := ast.Dump(stdout, program); err != nil {
+		fmt.Fprintf(stderr, "hua: %s\n", err)
+		return exitError
 	}
-	fmt.Printf("Hello, %s! This is the X progamming language", user.Name)
-	repl.Start(os.Stdin, os.Stdout)
+	return exitOK
 }
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func runHua(t *testing.T, stdin string, args ...string) (int, string, string) {
+	t.Helper()
+	var stdout, stderr bytes.Buffer
+	code := run(args, io.NopCloser(strings.NewReader(stdin)), &stdout, &stderr)
+	return code, stdout.String(), stderr.String()
+}
+
+func writeFile(t *testing.T, src string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "main.hua")
+	if err := os.WriteFile(path, []byte(src), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestRun(t *testing.T) {
+	path := writeFile(t, `for (a in args) { puts("hi " + a) }`)
+
+	code, stdout, stderr := runHua(t, "", "run", path, "ann", "bo")
+	if code != exitOK || stdout != "hi ann\nhi bo\n" || stderr != "" {
+		t.Errorf("wrong result: code=%d stdout=%q stderr=%q", code, stdout, stderr)
+	}
+}
+
+func TestRunErrors(t *testing.T) {
+	path := writeFile(t, "let x = 1;\nx + true")
+
+	code, _, stderr := runHua(t, "", "run", path)
+	if code != exitError {
+		t.Errorf("expected exit code %d, got %d", exitError, code)
+	}
+	if !strings.HasPrefix(stderr, path+":2:1: error[R0001]: type mismatch: INTEGER + BOOLEAN") {
+		t.Errorf("wrong errors, got %q", stderr)
+	}
+
+	code, _, stderr = runHua(t, "let = 1", "run", "-")
+	if code != exitError || !strings.HasPrefix(stderr, "<stdin>:1:5: error[P0001]") {
+		t.Errorf("wrong result for syntax error: code=%d stderr=%q", code, stderr)
+	}
+
+	code, _, stderr = runHua(t, "", "run", filepath.Join(t.TempDir(), "missing.hua"))
+	if code != exitError || !strings.HasPrefix(stderr, "hua: open ") {
+		t.Errorf("wrong result for missing file: code=%d stderr=%q", code, stderr)
+	}
+}
+
+func TestCheck(t *testing.T) {
+	code, _, stderr := runHua(t, "let f = fn() { puts(args, y) };", "check", "-")
+	if code != exitError {
+		t.Errorf("expected exit code %d, got %d", exitError, code)
+	}
+	if !strings.HasPrefix(stderr, "<stdin>:1:27: error[C0001]: undefined: y") {
+		t.Errorf("wrong errors, got %q", stderr)
+	}
+
+	code, stdout, stderr := runHua(t, "puts(1)", "check", "-")
+	if code != exitOK || stdout != "" || stderr != "" {
+		t.Errorf("check should not run the program: code=%d stdout=%q stderr=%q", code, stdout, stderr)
+	}
+}
+
+func TestTokensAndAst(t *testing.T) {
+	code, stdout, _ := runHua(t, "let x = 1", "tokens", "-")
+	expected := "1:1\tLET\t\"let\"\n1:5\tIDENTIFIER\t\"x\"\n1:7\t=\t\"=\"\n1:9\tINT\t\"1\"\n"
+	if code != exitOK || stdout != expected {
+		t.Errorf("wrong tokens: code=%d stdout=%q", code, stdout)
+	}
+
+	code, stdout, _ = runHua(t, "x", "ast", "-")
+	expected = "Program <stdin>:1:1\n  Statements[0]: ExpressionStatement <stdin>:1:1\n    Expression: Identifier <stdin>:1:1 Value=\"x\"\n"
+	if code != exitOK || stdout != expected {
+		t.Errorf("wrong ast: code=%d stdout=%q", code, stdout)
+	}
+
+	code, _, stderr := runHua(t, `"open`, "tokens", "-")
+	if code != exitError || !strings.Contains(stderr, "unterminated string literal") {
+		t.Errorf("wrong result for lexer error: code=%d stderr=%q", code, stderr)
+	}
+}
+
+func TestUsage(t *testing.T) {
+	tests := []struct {
+		args     []string
+		code     int
+		stdout   string
+		stderr   string
+		contains bool
+	}{
+		{[]string{"--version"}, exitOK, "hua dev\n", "", false},
+		{[]string{"--help"}, exitOK, "usage: hua <command>", "", true},
+		{[]string{"nope"}, exitUsage, "", "hua: unknown command \"nope\"", true},
+		{[]string{"run"}, exitUsage, "", "usage: hua run <file> [args]\n", false},
+		{[]string{"check", "a", "b"}, exitUsage, "", "usage: hua check <file>\n", false},
+	}
+
+	for _, tt := range tests {
+		code, stdout, stderr := runHua(t, "", tt.args...)
+		if code != tt.code {
+			t.Errorf("args %v: expected exit code %d, got %d", tt.args, tt.code, code)
+		}
+		if tt.contains {
+			if !strings.HasPrefix(stdout, tt.stdout) || !strings.HasPrefix(stderr, tt.stderr) {
+				t.Errorf("args %v: wrong output stdout=%q stderr=%q", tt.args, stdout, stderr)
+			}
+		} else if stdout != tt.stdout || stderr != tt.stderr {
+			t.Errorf("args %v: wrong output stdout=%q stderr=%q", tt.args, stdout, stderr)
+		}
+	}
+}
+
+func TestRepl(t *testing.T) {
+	code, stdout, _ := runHua(t, "1 + 2\n", "repl")
+	if code != exitOK || !strings.Contains(stdout, ">> 3\n") {
+		t.Errorf("wrong repl output: code=%d stdout=%q", code, stdout)
+	}
+}
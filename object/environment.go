@@ -0,0 +1,78 @@
+package object
+
+import "sort"
+
+type Environment struct {
+	store map[string]Object
+	outer *Environment
+	// calls counts the function calls in progress, shared by an environment
+	// and all scopes enclosed in it so that each program has its own count
+	calls *int
+}
+
+func NewEnvironment() *Environment {
+	return &Environment{store: make(map[string]Object), calls: new(int)}
+}
+
+// NewEnclosedEnvironment creates a scope for a function call, falling back to
+// outer for names that are not bound in the call itself.
+func NewEnclosedEnvironment(outer *Environment) *Environment {
+	return &Environment{store: make(map[string]Object), outer: outer, calls: outer.calls}
+}
+
+// EnterCall records that a function whose scope encloses e is being called
+// and returns the number of calls now in progress. It must be paired with
+// ExitCall.
+func (e *Environment) EnterCall() int {
+	*e.calls++
+	return *e.calls
+}
+
+// ExitCall records that a call counted by EnterCall has returned.
+func (e *Environment) ExitCall() {
+	*e.calls--
+}
+
+func (e *Environment) Get(name string) (Object, bool) {
+	obj, ok := e.store[name]
+	if !ok && e.outer != nil {
+		return e.outer.Get(name)
+	}
+	return obj, ok
+}
+
+// Set binds name in this scope, as done by let.
+func (e *Environment) Set(name string, val Object) Object {
+	e.store[name] = val
+	return val
+}
+
+// Assign rebinds name in the innermost scope that declares it. It reports
+// false, without binding anything, if name was never declared.
+func (e *Environment) Assign(name string, val Object) bool {
+	if _, ok := e.store[name]; ok {
+		e.store[name] = val
+		return true
+	}
+	if e.outer != nil {
+		return e.outer.Assign(name, val)
+	}
+	return false
+}
+
+// Names returns the names visible from this scope in sorted order, including
+// those of enclosing scopes.
+func (e *Environment) Names() []string {
+	seen := map[string]bool{}
+	for env := e; env != nil; env = env.outer {
+		for name := range env.store {
+			seen[name] = true
+		}
+	}
+	names := make([]string, 0, len(seen))
+	for name := range seen {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
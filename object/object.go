@@ -0,0 +1,237 @@
+package object
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"ljos.app/interpreter/ast"
+	"ljos.app/interpreter/diag"
+	"ljos.app/interpreter/token"
+)
+
+type ObjectType string
+
+const (
+	INTEGER_OBJ      = "INTEGER"
+	FLOAT_OBJ        = "FLOAT"
+	STRING_OBJ       = "STRING"
+	BOOLEAN_OBJ      = "BOOLEAN"
+	NULL_OBJ         = "NULL"
+	RETURN_VALUE_OBJ = "RETURN_VALUE"
+	ERROR_OBJ        = "ERROR"
+	FUNCTION_OBJ     = "FUNCTION"
+	ARRAY_OBJ        = "ARRAY"
+	HASH_OBJ         = "HASH"
+	BUILTIN_OBJ      = "BUILTIN"
+	BREAK_OBJ        = "BREAK"
+	CONTINUE_OBJ     = "CONTINUE"
+)
+
+type Object interface {
+	Type() ObjectType
+	Inspect() string
+}
+
+type Integer struct {
+	Value int64
+}
+
+func (i *Integer) Type() ObjectType { return INTEGER_OBJ }
+func (i *Integer) Inspect() string  { return fmt.Sprintf("%d", i.Value) }
+
+type Float struct {
+	Value float64
+}
+
+func (f *Float) Type() ObjectType { return FLOAT_OBJ }
+
+// Inspect always shows a fraction or exponent, so 3.0 is not mistaken for
+// the integer 3.
+func (f *Float) Inspect() string {
+	s := strconv.FormatFloat(f.Value, 'g', -1, 64)
+	if !strings.ContainsAny(s, ".eIN") {
+		s += ".0"
+	}
+	return s
+}
+
+type String struct {
+	Value string
+}
+
+func (s *String) Type() ObjectType { return STRING_OBJ }
+func (s *String) Inspect() string  { return s.Value }
+
+type Boolean struct {
+	Value bool
+}
+
+func (b *Boolean) Type() ObjectType { return BOOLEAN_OBJ }
+func (b *Boolean) Inspect() string  { return fmt.Sprintf("%t", b.Value) }
+
+type Null struct{}
+
+func (n *Null) Type() ObjectType { return NULL_OBJ }
+func (n *Null) Inspect() string  { return "null" }
+
+// ReturnValue wraps the value of a return statement while it unwinds
+// through enclosing blocks up to the function call or program.
+type ReturnValue struct {
+	Value Object
+}
+
+func (rv *ReturnValue) Type() ObjectType { return RETURN_VALUE_OBJ }
+func (rv *ReturnValue) Inspect() string  { return rv.Value.Inspect() }
+
+// Break and Continue unwind through enclosing blocks up to the innermost
+// loop, like ReturnValue does up to the function.
+type Break struct{}
+
+func (b *Break) Type() ObjectType { return BREAK_OBJ }
+func (b *Break) Inspect() string  { return "break" }
+
+type Continue struct{}
+
+func (c *Continue) Type() ObjectType { return CONTINUE_OBJ }
+func (c *Continue) Inspect() string  { return "continue" }
+
+type Error struct {
+	Message string
+	Span    token.Span // the innermost node that failed to evaluate
+}
+
+func (e *Error) Type() ObjectType { return ERROR_OBJ }
+func (e *Error) Inspect() string  { return "ERROR: " + e.Message }
+
+func (e *Error) Diagnostic() diag.Diagnostic {
+	return diag.Errorf(diag.RuntimeError, e.Span, "%s", e.Message)
+}
+
+type Function struct {
+	Parameters []*ast.Identifier
+	Body       *ast.BlockStatement
+	Env        *Environment // the environment the function was defined in
+}
+
+func (f *Function) Type() ObjectType { return FUNCTION_OBJ }
+func (f *Function) Inspect() string {
+	var out bytes.Buffer
+	params := []string{}
+	for _, p := range f.Parameters {
+		params = append(params, p.String())
+	}
+	out.WriteString("fn(")
+	out.WriteString(strings.Join(params, ", "))
+	out.WriteString(") {")
+	out.WriteString(f.Body.String())
+	out.WriteString("}")
+	return out.String()
+}
+
+type BuiltinFunction func(args ...Object) Object
+
+// Builtin is a function implemented in Go, such as a string method bound to
+// its receiver.
+type Builtin struct {
+	Name string
+	Fn   BuiltinFunction
+}
+
+func (b *Builtin) Type() ObjectType { return BUILTIN_OBJ }
+func (b *Builtin) Inspect() string  { return "builtin " + b.Name }
+
+type Array struct {
+	Elements []Object
+}
+
+func (a *Array) Type() ObjectType { return ARRAY_OBJ }
+func (a *Array) Inspect() string {
+	var out bytes.Buffer
+	elements := []string{}
+	for _, e := range a.Elements {
+		elements = append(elements, inspectElement(e))
+	}
+	out.WriteString("[")
+	out.WriteString(strings.Join(elements, ", "))
+	out.WriteString("]")
+	return out.String()
+}
+
+// HashKey identifies a hash entry. Keys of different types never collide,
+// so 1 and "1" are distinct keys.
+type HashKey struct {
+	Type  ObjectType
+	Value string
+}
+
+// Hashable is implemented by the objects that can be used as hash keys.
+type Hashable interface {
+	Object
+	HashKey() HashKey
+}
+
+func (i *Integer) HashKey() HashKey {
+	return HashKey{Type: i.Type(), Value: strconv.FormatInt(i.Value, 10)}
+}
+
+func (b *Boolean) HashKey() HashKey {
+	return HashKey{Type: b.Type(), Value: strconv.FormatBool(b.Value)}
+}
+
+func (s *String) HashKey() HashKey {
+	return HashKey{Type: s.Type(), Value: s.Value}
+}
+
+type HashPair struct {
+	Key   Object
+	Value Object
+}
+
+// Hash maps hashable keys to values and remembers the order in which keys
+// were first inserted.
+type Hash struct {
+	Pairs map[HashKey]HashPair
+	Keys  []HashKey // insertion order
+}
+
+func NewHash() *Hash {
+	return &Hash{Pairs: make(map[HashKey]HashPair)}
+}
+
+func (h *Hash) Get(key Hashable) (Object, bool) {
+	pair, ok := h.Pairs[key.HashKey()]
+	return pair.Value, ok
+}
+
+func (h *Hash) Set(key Hashable, value Object) {
+	hashKey := key.HashKey()
+	if _, ok := h.Pairs[hashKey]; !ok {
+		h.Keys = append(h.Keys, hashKey)
+	}
+	h.Pairs[hashKey] = HashPair{Key: key, Value: value}
+}
+
+func (h *Hash) Type() ObjectType { return HASH_OBJ }
+func (h *Hash) Inspect() string {
+	var out bytes.Buffer
+	pairs := []string{}
+	for _, key := range h.Keys {
+		pair := h.Pairs[key]
+		pairs = append(pairs, inspectElement(pair.Key)+": "+inspectElement(pair.Value))
+	}
+	out.WriteString("{")
+	out.WriteString(strings.Join(pairs, ", "))
+	out.WriteString("}")
+	return out.String()
+}
+
+// inspectElement quotes strings inside arrays and hashes, so ["a"] is not
+// shown the same as [a].
+func inspectElement(obj Object) string {
+	if s, ok := obj.(*String); ok {
+		return strconv.Quote(s.Value)
+	}
+	return obj.Inspect()
+}
@@ -0,0 +1,63 @@
+package object
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestHashKeys(t *testing.T) {
+	hello1 := &String{Value: "Hello World"}
+	hello2 := &String{Value: "Hello World"}
+	diff := &String{Value: "My name is johnny"}
+
+	if hello1.HashKey() != hello2.HashKey() {
+		t.Errorf("strings with same content have different hash keys")
+	}
+	if hello1.HashKey() == diff.HashKey() {
+		t.Errorf("strings with different content have same hash keys")
+	}
+
+	one := &Integer{Value: 1}
+	oneString := &String{Value: "1"}
+	if one.HashKey() == oneString.HashKey() {
+		t.Errorf("1 and \"1\" have the same hash key")
+	}
+	if (&Boolean{Value: true}).HashKey() != (&Boolean{Value: true}).HashKey() {
+		t.Errorf("booleans with same value have different hash keys")
+	}
+}
+
+func TestHashInsertionOrder(t *testing.T) {
+	hash := NewHash()
+	hash.Set(&String{Value: "b"}, &Integer{Value: 1})
+	hash.Set(&Integer{Value: 2}, &String{Value: "two"})
+	hash.Set(&String{Value: "a"}, &Boolean{Value: true})
+	hash.Set(&String{Value: "b"}, &Integer{Value: 3})
+
+	expected := `{"b": 3, 2: "two", "a": true}`
+	if hash.Inspect() != expected {
+		t.Errorf("wrong Inspect(). expected=%q, got=%q", expected, hash.Inspect())
+	}
+
+	val, ok := hash.Get(&String{Value: "b"})
+	if !ok || val.Inspect() != "3" {
+		t.Errorf("wrong value for \"b\". got=%v (%t)", val, ok)
+	}
+	if _, ok := hash.Get(&String{Value: "c"}); ok {
+		t.Errorf("found value for missing key \"c\"")
+	}
+}
+
+func TestEnvironmentNames(t *testing.T) {
+	outer := NewEnvironment()
+	outer.Set("b", &Integer{Value: 1})
+	outer.Set("a", &Integer{Value: 2})
+	inner := NewEnclosedEnvironment(outer)
+	inner.Set("c", &Integer{Value: 3})
+	inner.Set("a", &Integer{Value: 4})
+
+	got := strings.Join(inner.Names(), ",")
+	if got != "a,b,c" {
+		t.Errorf("wrong names. expected=%q, got=%q", "a,b,c", got)
+	}
+}
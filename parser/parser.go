@@ -2,9 +2,12 @@ package parser
 
 import (
 	"fmt"
+	"sort"
+	"strconv"
 	"strings"
 
 	"ljos.app/interpreter/ast"
+	"ljos.app/interpreter/diag"
 	"ljos.app/interpreter/lexer"
 	"ljos.app/interpreter/token"
 )
@@ -12,27 +15,82 @@ import (
 const (
 	_ int = iota
 	LOWEST
+	ASSIGNMENT  // x = y or x += y
+	LOGICAL_OR  // ||
+	LOGICAL_AND // &&
 	EQUALS      // ==
 	LESSGREATER // > or <
-	SUM         // +
-	PRODUCT     // *
+	SHIFT       // << or >>
+	SUM         // +, | or ^
+	PRODUCT     // *, % or &
 	PREFIX      // -X or !X
+	POWER       // X ** Y
 	CALL        // myFunction(X)
+	INDEX       // array[index]
+	MEMBER      // object.member
 )
 
-type ParserError struct {
-	Error string
-	Lines string
+var precedences = map[token.TokenType]int{
+	token.ASSIGN:       ASSIGNMENT,
+	token.PLUS_ASSIGN:  ASSIGNMENT,
+	token.MINUS_ASSIGN: ASSIGNMENT,
+	token.MUL_ASSIGN:   ASSIGNMENT,
+	token.DIV_ASSIGN:   ASSIGNMENT,
+	token.MOD_ASSIGN:   ASSIGNMENT,
+	token.POW_ASSIGN:   ASSIGNMENT,
+	token.AND_ASSIGN:   ASSIGNMENT,
+	token.OR_ASSIGN:    ASSIGNMENT,
+	token.XOR_ASSIGN:   ASSIGNMENT,
+	token.SHL_ASSIGN:   ASSIGNMENT,
+	token.SHR_ASSIGN:   ASSIGNMENT,
+	token.LOGICAL_OR:   LOGICAL_OR,
+	token.LOGICAL_AND:  LOGICAL_AND,
+	token.PIPE:         SUM,
+	token.CARET:        SUM,
+	token.AMPERSAND:    PRODUCT,
+	token.EQUAL:        EQUALS,
+	token.NOT_EQUAL:    EQUALS,
+	token.LESS_THAN:    LESSGREATER,
+	token.GREATER_THAN: LESSGREATER,
+	token.LESS_THAN_EQ: LESSGREATER,
+	token.GRTR_THAN_EQ: LESSGREATER,
+	token.SHIFT_LEFT:   SHIFT,
+	token.SHIFT_RIGHT:  SHIFT,
+	token.PLUS:         SUM,
+	token.MINUS:        SUM,
+	token.SLASH:        PRODUCT,
+	token.ASTERISK:     PRODUCT,
+	token.PERCENT:      PRODUCT,
+	token.POWER:        POWER,
+	token.LPAREN:       CALL,
+	token.LBRACK:       INDEX,
+	token.DOT:          MEMBER,
 }
+
+// MaxErrors is the number of errors, from the lexer and the parser together,
+// after which ParseProgram gives up.
+const MaxErrors = 10
+
 type Parser struct {
 	l      *lexer.Lexer
-	errors []ParserError
+	errors []diag.Diagnostic
+	// panicking is set when an error is reported and cleared once the parser
+	// has skipped to the next statement, errors in between are dropped
+	panicking bool
+	// loopDepth counts the loops enclosing the current statement within the
+	// current function, break and continue are only valid when it is > 0
+	loopDepth int
+	// closers holds the closing delimiters that the enclosing constructs are
+	// waiting for, innermost last
+	closers []token.TokenType
+	// tooManyErrors is set once ParseProgram gives up, it is always reported
+	// after the other errors
+	tooManyErrors *diag.Diagnostic
 
+	prevToken token.Token
 	curToken  token.Token
 	peekToken token.Token
-
-	currentLines   []string
-	currentLineIdx int
+	pushback  *token.Token
 
 	prefixParseFns map[token.TokenType]prefixParseFn
 	infixParseFns  map[token.TokenType]infixParseFn
@@ -54,11 +112,59 @@ func (p *Parser) registerInfix(tokenType token.TokenType, fn infixParseFn) {
 func New(l *lexer.Lexer) *Parser {
 	p := Parser{
 		l:      l,
-		errors: []ParserError{},
+		errors: []diag.Diagnostic{},
 	}
 	p.prefixParseFns = make(map[token.TokenType]prefixParseFn)
 	p.registerPrefix(token.IDENTIFIER, p.parseIdentifier)
+	p.registerPrefix(token.INT, p.parseIntegerLiteral)
+	p.registerPrefix(token.FLOAT, p.parseFloatLiteral)
+	p.registerPrefix(token.STRING, p.parseStringLiteral)
+	p.registerPrefix(token.TRUE, p.parseBoolean)
+	p.registerPrefix(token.FALSE, p.parseBoolean)
+	p.registerPrefix(token.BANG, p.parsePrefixExpression)
+	p.registerPrefix(token.MINUS, p.parsePrefixExpression)
+	p.registerPrefix(token.LPAREN, p.parseGroupedExpression)
+	p.registerPrefix(token.IF, p.parseIfExpression)
+	p.registerPrefix(token.LBRACK, p.parseArrayLiteral)
+	p.registerPrefix(token.LBRACE, p.parseHashLiteral)
+
+	p.registerPrefix(token.FUNCTION, p.parseFunctionLiteral)
+
 	p.infixParseFns = make(map[token.TokenType]infixParseFn)
+	p.registerInfix(token.PLUS, p.parseInfixExpression)
+	p.registerInfix(token.MINUS, p.parseInfixExpression)
+	p.registerInfix(token.SLASH, p.parseInfixExpression)
+	p.registerInfix(token.ASTERISK, p.parseInfixExpression)
+	p.registerInfix(token.EQUAL, p.parseInfixExpression)
+	p.registerInfix(token.NOT_EQUAL, p.parseInfixExpression)
+	p.registerInfix(token.LESS_THAN, p.parseInfixExpression)
+	p.registerInfix(token.GREATER_THAN, p.parseInfixExpression)
+	p.registerInfix(token.LESS_THAN_EQ, p.parseInfixExpression)
+	p.registerInfix(token.GRTR_THAN_EQ, p.parseInfixExpression)
+	p.registerInfix(token.PERCENT, p.parseInfixExpression)
+	p.registerInfix(token.POWER, p.parseInfixExpression)
+	p.registerInfix(token.AMPERSAND, p.parseInfixExpression)
+	p.registerInfix(token.PIPE, p.parseInfixExpression)
+	p.registerInfix(token.CARET, p.parseInfixExpression)
+	p.registerInfix(token.SHIFT_LEFT, p.parseInfixExpression)
+	p.registerInfix(token.SHIFT_RIGHT, p.parseInfixExpression)
+	p.registerInfix(token.LOGICAL_AND, p.parseInfixExpression)
+	p.registerInfix(token.LOGICAL_OR, p.parseInfixExpression)
+	p.registerInfix(token.LPAREN, p.parseCallExpression)
+	p.registerInfix(token.LBRACK, p.parseIndexExpression)
+	p.registerInfix(token.DOT, p.parseMemberExpression)
+	p.registerInfix(token.ASSIGN, p.parseAssignExpression)
+	p.registerInfix(token.PLUS_ASSIGN, p.parseAssignExpression)
+	p.registerInfix(token.MINUS_ASSIGN, p.parseAssignExpression)
+	p.registerInfix(token.MUL_ASSIGN, p.parseAssignExpression)
+	p.registerInfix(token.DIV_ASSIGN, p.parseAssignExpression)
+	p.registerInfix(token.MOD_ASSIGN, p.parseAssignExpression)
+	p.registerInfix(token.POW_ASSIGN, p.parseAssignExpression)
+	p.registerInfix(token.AND_ASSIGN, p.parseAssignExpression)
+	p.registerInfix(token.OR_ASSIGN, p.parseAssignExpression)
+	p.registerInfix(token.XOR_ASSIGN, p.parseAssignExpression)
+	p.registerInfix(token.SHL_ASSIGN, p.parseAssignExpression)
+	p.registerInfix(token.SHR_ASSIGN, p.parseAssignExpression)
 
 	// fill curToken and peekToken
 	p.nextToken()
@@ -69,60 +175,643 @@ func (p *Parser) parseIdentifier() ast.Expression {
 	return &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
 }
 
+func (p *Parser) parseIntegerLiteral() ast.Expression {
+	lit := &ast.IntegerLiteral{Token: p.curToken}
+	digits := strings.ReplaceAll(p.curToken.Literal, "_", "")
+	base := 10
+	if len(digits) > 2 && digits[0] == '0' {
+		switch digits[1] {
+		case 'x', 'X':
+			base = 16
+		case 'o', 'O':
+			base = 8
+		case 'b', 'B':
+			base = 2
+		}
+	}
+	if base != 10 {
+		digits = digits[2:]
+	}
+	value, err := strconv.ParseInt(digits, base, 64)
+	if err != nil {
+		p.addError(diag.InvalidNumber, p.curToken.Span(), "could not parse %q as integer", p.curToken.Literal)
+		return nil
+	}
+	lit.Value = value
+	return lit
+}
+
+func (p *Parser) parseFloatLiteral() ast.Expression {
+	lit := &ast.FloatLiteral{Token: p.curToken}
+	value, err := strconv.ParseFloat(strings.ReplaceAll(p.curToken.Literal, "_", ""), 64)
+	if err != nil {
+		p.addError(diag.InvalidNumber, p.curToken.Span(), "could not parse %q as float", p.curToken.Literal)
+		return nil
+	}
+	lit.Value = value
+	return lit
+}
+
+func (p *Parser) parseStringLiteral() ast.Expression {
+	return &ast.StringLiteral{Token: p.curToken, Value: p.curToken.Literal}
+}
+
+func (p *Parser) parseBoolean() ast.Expression {
+	return &ast.Boolean{Token: p.curToken, Value: p.curTokenIs(token.TRUE)}
+}
+
+func (p *Parser) parsePrefixExpression() ast.Expression {
+	expression := &ast.PrefixExpression{
+		Token:    p.curToken,
+		Operator: p.curToken.Literal,
+	}
+	p.nextToken()
+	expression.Right = p.parseExpression(PREFIX)
+	return expression
+}
+
+func (p *Parser) parseInfixExpression(left ast.Expression) ast.Expression {
+	expression := &ast.InfixExpression{
+		Token:    p.curToken,
+		Operator: p.curToken.Literal,
+		Left:     left,
+	}
+	precedence := p.curPrecedence()
+	if p.curTokenIs(token.POWER) {
+		// ** is right associative, 2 ** 3 ** 2 is 2 ** (3 ** 2)
+		precedence--
+	}
+	p.nextToken()
+	expression.Right = p.parseExpression(precedence)
+	return expression
+}
+
+// parseAssignExpression parses the right-hand side of an assignment with a
+// lower precedence than ASSIGNMENT, making `a = b = 1` assign to b first.
+func (p *Parser) parseAssignExpression(target ast.Expression) ast.Expression {
+	expression := &ast.AssignExpression{
+		Token:    p.curToken,
+		Target:   target,
+		Operator: p.curToken.Literal,
+	}
+	switch target.(type) {
+	case *ast.Identifier, *ast.IndexExpression, *ast.MemberExpression:
+		// assignable
+	default:
+		p.addError(diag.InvalidAssignment, token.Span{Start: target.Pos(), End: target.End()},
+			"cannot assign to %s", target.String())
+		return nil
+	}
+	p.nextToken()
+	expression.Value = p.parseExpression(ASSIGNMENT - 1)
+	if expression.Value == nil {
+		return nil
+	}
+	return expression
+}
+
+// parseGroupedExpression parses `(a + b)` as well as the parameter list of an
+// arrow lambda `(a, b) => a + b`. The contents are parsed as a list of
+// expressions and only checked to be identifiers once the => is seen.
+func (p *Parser) parseGroupedExpression() ast.Expression {
+	lparen := p.curToken
+	if p.peekTokenIs(token.RPAREN) {
+		// empty parens are only valid as the parameter list of a lambda
+		p.nextToken()
+		if !p.expectedToken(token.LAMBDA) {
+			return nil
+		}
+		return p.parseLambdaBody(lparen, []*ast.Identifier{})
+	}
+
+	exps := p.parseExpressionList(token.RPAREN)
+	if exps == nil {
+		return nil
+	}
+
+	if p.peekTokenIs(token.LAMBDA) {
+		p.nextToken()
+		params := []*ast.Identifier{}
+		for _, exp := range exps {
+			ident, ok := exp.(*ast.Identifier)
+			if !ok {
+				p.addError(diag.InvalidParameter, token.Span{Start: exp.Pos(), End: exp.End()},
+					"expected lambda parameter to be an identifier, got %s instead", exp.String())
+				return nil
+			}
+			params = append(params, ident)
+		}
+		return p.parseLambdaBody(lparen, params)
+	}
+	if len(exps) != 1 {
+		p.addError(diag.UnexpectedToken, p.peekToken.Span(), "expected %s after parameter list, got %s instead",
+			token.LAMBDA, p.peekToken.Type)
+		return nil
+	}
+	return exps[0]
+}
+
+func (p *Parser) parseLambdaBody(lparen token.Token, params []*ast.Identifier) ast.Expression {
+	lambda := &ast.FunctionLiteral{Token: p.curToken, Lparen: lparen.Pos, Parameters: params}
+	defer p.enterFunction()()
+	if p.peekTokenIs(token.LBRACE) {
+		p.nextToken()
+		if lambda.Body = p.parseBlockStatement(); lambda.Body == nil {
+			return nil
+		}
+		return lambda
+	}
+
+	p.nextToken()
+	stmt := &ast.ExpressionStatement{Token: p.curToken}
+	if stmt.Expression = p.parseExpression(LOWEST); stmt.Expression == nil {
+		return nil
+	}
+	lambda.Body = &ast.BlockStatement{
+		Token:      stmt.Token,
+		Statements: []ast.Statement{stmt},
+	}
+	return lambda
+}
+
+// enterFunction hides the enclosing loops while parsing a function body, as
+// break and continue cannot jump out of a function. The returned func
+// restores them.
+func (p *Parser) enterFunction() func() {
+	loopDepth := p.loopDepth
+	p.loopDepth = 0
+	return func() { p.loopDepth = loopDepth }
+}
+
+func (p *Parser) parseFunctionLiteral() ast.Expression {
+	lit := &ast.FunctionLiteral{Token: p.curToken}
+	defer p.enterFunction()()
+	if !p.expectedToken(token.LPAREN) {
+		return nil
+	}
+	if lit.Parameters = p.parseFunctionParameters(); lit.Parameters == nil {
+		return nil
+	}
+	if !p.expectedToken(token.LBRACE) {
+		return nil
+	}
+	if lit.Body = p.parseBlockStatement(); lit.Body == nil {
+		return nil
+	}
+	return lit
+}
+
+func (p *Parser) parseFunctionParameters() []*ast.Identifier {
+	identifiers := []*ast.Identifier{}
+	if p.peekTokenIs(token.RPAREN) {
+		p.nextToken()
+		return identifiers
+	}
+
+	if !p.expectedToken(token.IDENTIFIER) {
+		return nil
+	}
+	identifiers = append(identifiers, &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal})
+	for p.peekTokenIs(token.COMMA) {
+		p.nextToken()
+		if !p.expectedToken(token.IDENTIFIER) {
+			return nil
+		}
+		identifiers = append(identifiers, &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal})
+	}
+
+	if !p.expectedToken(token.RPAREN) {
+		return nil
+	}
+	return identifiers
+}
+
+func (p *Parser) parseCallExpression(function ast.Expression) ast.Expression {
+	exp := &ast.CallExpression{Token: p.curToken, Function: function}
+	if exp.Arguments = p.parseExpressionList(token.RPAREN); exp.Arguments == nil {
+		return nil
+	}
+	exp.Rparen = p.curToken
+	return exp
+}
+
+func (p *Parser) parseArrayLiteral() ast.Expression {
+	array := &ast.ArrayLiteral{Token: p.curToken}
+	if array.Elements = p.parseExpressionList(token.RBRACK); array.Elements == nil {
+		return nil
+	}
+	array.Rbrack = p.curToken
+	return array
+}
+
+// parseExpressionList parses comma separated expressions up to and including
+// the end token, as in call arguments, array literals and parens.
+func (p *Parser) parseExpressionList(end token.TokenType) []ast.Expression {
+	defer p.openDelimiter(end)()
+	list := []ast.Expression{}
+	if p.peekTokenIs(end) {
+		p.nextToken()
+		return list
+	}
+
+	p.nextToken()
+	exp := p.parseExpression(LOWEST)
+	if exp == nil {
+		return nil
+	}
+	list = append(list, exp)
+	for p.peekTokenIs(token.COMMA) {
+		p.nextToken()
+		p.nextToken()
+		if exp = p.parseExpression(LOWEST); exp == nil {
+			return nil
+		}
+		list = append(list, exp)
+	}
+
+	if !p.expectedToken(end) {
+		return nil
+	}
+	return list
+}
+
+func (p *Parser) parseHashLiteral() ast.Expression {
+	hash := &ast.HashLiteral{Token: p.curToken, Pairs: []ast.HashPair{}}
+	defer p.openDelimiter(token.RBRACE)()
+	for !p.peekTokenIs(token.RBRACE) {
+		p.nextToken()
+		key := p.parseExpression(LOWEST)
+		if key == nil {
+			return nil
+		}
+		if !p.expectedToken(token.COLON) {
+			return nil
+		}
+		p.nextToken()
+		value := p.parseExpression(LOWEST)
+		if value == nil {
+			return nil
+		}
+		hash.Pairs = append(hash.Pairs, ast.HashPair{Key: key, Value: value})
+		if !p.peekTokenIs(token.RBRACE) && !p.expectedToken(token.COMMA) {
+			return nil
+		}
+	}
+	p.nextToken()
+	hash.Rbrace = p.curToken
+	return hash
+}
+
+func (p *Parser) parseMemberExpression(object ast.Expression) ast.Expression {
+	exp := &ast.MemberExpression{Token: p.curToken, Object: object}
+	if !p.expectedToken(token.IDENTIFIER) {
+		return nil
+	}
+	exp.Property = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+	return exp
+}
+
+// parseIndexExpression parses `xs[i]` and the slices `xs[low:high]`,
+// `xs[low:]`, `xs[:high]` and `xs[:]`.
+func (p *Parser) parseIndexExpression(left ast.Expression) ast.Expression {
+	lbrack := p.curToken
+	defer p.openDelimiter(token.RBRACK)()
+	var low ast.Expression
+	if !p.peekTokenIs(token.COLON) {
+		p.nextToken()
+		if low = p.parseExpression(LOWEST); low == nil {
+			return nil
+		}
+		if !p.peekTokenIs(token.COLON) {
+			if !p.expectedToken(token.RBRACK) {
+				return nil
+			}
+			return &ast.IndexExpression{Token: lbrack, Left: left, Index: low, Rbrack: p.curToken}
+		}
+	}
+
+	p.nextToken() // the :
+	slice := &ast.SliceExpression{Token: lbrack, Left: left, Low: low}
+	if !p.peekTokenIs(token.RBRACK) {
+		p.nextToken()
+		if slice.High = p.parseExpression(LOWEST); slice.High == nil {
+			return nil
+		}
+	}
+	if !p.expectedToken(token.RBRACK) {
+		return nil
+	}
+	slice.Rbrack = p.curToken
+	return slice
+}
+
+func (p *Parser) peekPrecedence() int {
+	if prec, ok := precedences[p.peekToken.Type]; ok {
+		return prec
+	}
+	return LOWEST
+}
+
+func (p *Parser) curPrecedence() int {
+	if prec, ok := precedences[p.curToken.Type]; ok {
+		return prec
+	}
+	return LOWEST
+}
+
 func (p *Parser) nextToken() {
+	p.prevToken = p.curToken
 	p.curToken = p.peekToken
+	if p.pushback != nil {
+		p.peekToken = *p.pushback
+		p.pushback = nil
+		return
+	}
 	p.peekToken = p.l.NextToken()
-	p.currentLines = p.l.GetCurrentLines()
-	p.currentLineIdx = p.l.CurrentLineIdx
 }
 
-func (p *Parser) Errors() []ParserError {
-	return p.errors
+// openDelimiter records that the construct being parsed ends with the closing
+// delimiter t, until the returned func is called.
+func (p *Parser) openDelimiter(t token.TokenType) func() {
+	p.closers = append(p.closers, t)
+	return func() { p.closers = p.closers[:len(p.closers)-1] }
 }
 
-func (p *Parser) peekError(t token.TokenType) {
-	lines := strings.Join(p.currentLines, "\n")
-	msg := fmt.Sprintf("expected next token to be %s, got %s instead (on line %d)",
-		t, p.peekToken.Type, p.currentLineIdx)
-	p.errors = append(p.errors, ParserError{
-		Error: msg,
-		Lines: lines,
+// isCloser reports whether t closes the innermost enclosing construct.
+func (p *Parser) isCloser(t token.TokenType) bool {
+	return len(p.closers) > 0 && p.closers[len(p.closers)-1] == t
+}
+
+// backup undoes the last nextToken. Only a single step can be undone.
+func (p *Parser) backup() {
+	peek := p.peekToken
+	p.pushback = &peek
+	p.peekToken = p.curToken
+	p.curToken = p.prevToken
+}
+
+// Errors returns the diagnostics of both the lexer and the parser, ordered by
+// their position in the source. If the parser gave up, saying so comes last.
+func (p *Parser) Errors() []diag.Diagnostic {
+	errors := append([]diag.Diagnostic{}, p.l.Errors()...)
+	errors = append(errors, p.errors...)
+	sort.SliceStable(errors, func(i, j int) bool {
+		return errors[i].Span.Start.Offset < errors[j].Span.Start.Offset
 	})
+	tooMany := p.tooManyErrors
+	if len(errors) > MaxErrors {
+		// the lexer may have found more while the parser skipped tokens
+		if tooMany == nil {
+			d := diag.Errorf(diag.TooManyErrors, errors[MaxErrors].Span, "too many errors, stopping after %d", MaxErrors)
+			tooMany = &d
+		}
+		errors = errors[:MaxErrors]
+	}
+	if tooMany != nil {
+		errors = append(errors, *tooMany)
+	}
+	return errors
+}
+
+// Incomplete reports whether the errors of ParseProgram were all caused by
+// the input ending too early, as with an unclosed block, paren or string.
+// More input could then still make the program valid, while other errors
+// mean the input is erroneous no matter what follows.
+func (p *Parser) Incomplete() bool {
+	errors := p.Errors()
+	if len(errors) == 0 || !p.curTokenIs(token.EOF) {
+		return false
+	}
+	for _, d := range errors {
+		switch {
+		case d.Code == diag.UnterminatedString, d.Code == diag.UnterminatedComment:
+		case d.Span.Start.Offset >= p.curToken.Pos.Offset:
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// errorCount is the number of errors found so far by the lexer and parser.
+func (p *Parser) errorCount() int {
+	return len(p.l.Errors()) + len(p.errors)
+}
+
+func (p *Parser) report(d diag.Diagnostic) {
+	if p.panicking {
+		return
+	}
+	p.panicking = true
+	if p.errorCount() < MaxErrors {
+		p.errors = append(p.errors, d)
+	}
+}
+
+func (p *Parser) addError(code diag.Code, span token.Span, format string, args ...any) {
+	p.report(diag.Errorf(code, span, format, args...))
+}
+
+func (p *Parser) peekError(t token.TokenType) {
+	d := diag.Errorf(diag.UnexpectedToken, p.peekToken.Span(), "expected next token to be %s, got %s instead",
+		t, p.peekToken.Type)
+	switch t {
+	case token.RPAREN, token.RBRACE, token.RBRACK, token.SEMICOLON:
+		// closing delimiters can be inserted right after the current token
+		d.Fixes = []diag.Fix{insertFix(p.curToken.End, t)}
+	}
+	p.report(d)
+}
+
+func (p *Parser) noPrefixParseFnError(tok token.Token) {
+	if tok.Type == token.ILLEGAL {
+		// already reported by the lexer, but the statement still needs recovery
+		p.panicking = true
+		return
+	}
+	p.addError(diag.ExpectedExpression, tok.Span(), "expected an expression, got %s instead", tok.Type)
+}
+
+func insertFix(pos token.Position, t token.TokenType) diag.Fix {
+	return diag.Fix{
+		Message:     fmt.Sprintf("insert %q", string(t)),
+		Span:        token.Span{Start: pos, End: pos},
+		Replacement: string(t),
+	}
 }
 
 func (p *Parser) ParseProgram() *ast.Program {
 	program := &ast.Program{}
 	program.Statements = []ast.Statement{}
 	for p.curToken.Type != token.EOF {
-		statement := p.parseStatement()
+		statement := p.parseStatementWithRecovery()
 
 		if statement != nil {
 			program.Statements = append(program.Statements, statement)
 		}
+		if p.errorCount() >= MaxErrors {
+			d := diag.Errorf(diag.TooManyErrors, p.curToken.Span(), "too many errors, stopping after %d", MaxErrors)
+			p.tooManyErrors = &d
+			break
+		}
 		p.nextToken()
 	}
+	for _, comment := range p.l.Comments() {
+		program.Comments = append(program.Comments, &ast.Comment{Token: comment})
+	}
 	return program
 }
 
-func (p *Parser) parseStatement() ast.Statement {
+// parseStatementWithRecovery parses a statement and, if an error was reported
+// while doing so, skips ahead to the start of the next statement. Statements
+// that could not be parsed at all are replaced by an ast.BadStatement.
+func (p *Parser) parseStatementWithRecovery() ast.Statement {
+	from := p.curToken
+	statement := p.parseStatement()
+	if !p.panicking {
+		return statement
+	}
 
-	var statement ast.Statement
-
-	if p.curToken.Type == token.LET {
-		// fmt.Printf("parseStatement curToken type is %s\n", p.curToken.Type)
-		statement = p.parseLetStatement()
-	} else if p.curToken.Type == token.RETURN {
-		// fmt.Printf("parseStatement curToken type is %s\n", p.curToken.Type)
-		statement = p.parseReturnStatement()
-	} else if p.curToken.Type == token.IF {
-		// fmt.Printf("parseStatement curToken type is %s\n", p.curToken.Type)
-		statement = p.parseIfStatement()
-	} else {
-		// fmt.Printf("parseStatement curToken type is %s\n", p.curToken.Type)
-		return p.parseExpressionStatement()
+	p.synchronize()
+	p.panicking = false
+	if p.curToken.Pos.Offset < from.Pos.Offset {
+		// backed up to before the statement, skip the token it started with
+		// so that the caller does not parse it again
+		p.nextToken()
+	}
+	if statement == nil {
+		return &ast.BadStatement{From: from, To: p.curToken}
 	}
 	return statement
+}
+
+// synchronize advances until the current token is a ; or the next token
+// closes the enclosing block or starts a new statement.
+func (p *Parser) synchronize() {
+	for !p.curTokenIs(token.SEMICOLON) && !p.curTokenIs(token.EOF) {
+		switch p.peekToken.Type {
+		case token.LET, token.RETURN, token.IF, token.WHILE, token.FOR, token.BREAK, token.CONTINUE,
+			token.FUNCTION, token.RBRACE, token.EOF:
+			return
+		}
+		p.nextToken()
+	}
+}
+
+func (p *Parser) parseStatement() ast.Statement {
+	// return nil explicitly on failure, a nil *ast.LetStatement stored in an
+	// ast.Statement is not == nil
+	switch p.curToken.Type {
+	case token.LET:
+		if stmt := p.parseLetStatement(); stmt != nil {
+			return stmt
+		}
+	case token.RETURN:
+		if stmt := p.parseReturnStatement(); stmt != nil {
+			return stmt
+		}
+	case token.IF:
+		if stmt := p.parseIfStatement(); stmt != nil {
+			if p.peekTokenIs(token.SEMICOLON) {
+				p.nextToken()
+			}
+			return stmt
+		}
+	case token.WHILE:
+		if stmt := p.parseWhileStatement(); stmt != nil {
+			if p.peekTokenIs(token.SEMICOLON) {
+				p.nextToken()
+			}
+			return stmt
+		}
+	case token.FOR:
+		if stmt := p.parseForStatement(); stmt != nil {
+			if p.peekTokenIs(token.SEMICOLON) {
+				p.nextToken()
+			}
+			return stmt
+		}
+	case token.BREAK:
+		return p.parseBreakStatement()
+	case token.CONTINUE:
+		return p.parseContinueStatement()
+	case token.SEMICOLON:
+		// empty statement
+	default:
+		if stmt := p.parseExpressionStatement(); stmt != nil {
+			return stmt
+		}
+	}
+	return nil
+}
 
+func (p *Parser) parseWhileStatement() *ast.WhileStatement {
+	stmt := &ast.WhileStatement{Token: p.curToken}
+	p.nextToken()
+	if stmt.Condition = p.parseExpression(LOWEST); stmt.Condition == nil {
+		return nil
+	}
+	if stmt.Body = p.parseLoopBody(); stmt.Body == nil {
+		return nil
+	}
+	return stmt
+}
+
+func (p *Parser) parseForStatement() *ast.ForStatement {
+	stmt := &ast.ForStatement{Token: p.curToken}
+	if !p.expectedToken(token.LPAREN) || !p.expectedToken(token.IDENTIFIER) {
+		return nil
+	}
+	stmt.Variable = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+	if !p.expectedToken(token.IN) {
+		return nil
+	}
+	p.nextToken()
+	if stmt.Iterable = p.parseExpression(LOWEST); stmt.Iterable == nil {
+		return nil
+	}
+	if !p.expectedToken(token.RPAREN) {
+		return nil
+	}
+	if stmt.Body = p.parseLoopBody(); stmt.Body == nil {
+		return nil
+	}
+	return stmt
+}
+
+// parseLoopBody parses the block of a loop, in which break and continue are
+// allowed.
+func (p *Parser) parseLoopBody() *ast.BlockStatement {
+	if !p.expectedToken(token.LBRACE) {
+		return nil
+	}
+	p.loopDepth++
+	defer func() { p.loopDepth-- }()
+	return p.parseBlockStatement()
+}
+
+func (p *Parser) parseBreakStatement() *ast.BreakStatement {
+	stmt := &ast.BreakStatement{Token: p.curToken}
+	p.checkInLoop(stmt.Token)
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+	return stmt
+}
+
+func (p *Parser) parseContinueStatement() *ast.ContinueStatement {
+	stmt := &ast.ContinueStatement{Token: p.curToken}
+	p.checkInLoop(stmt.Token)
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+	return stmt
+}
+
+func (p *Parser) checkInLoop(tok token.Token) {
+	if p.loopDepth == 0 {
+		p.addError(diag.OutsideLoop, tok.Span(), "%s outside of a loop", tok.Literal)
+	}
 }
 
 func (p *Parser) expectedToken(expectedToken token.TokenType) bool {
@@ -150,25 +839,66 @@ func (p *Parser) parseLetStatement() *ast.LetStatement {
 	if !p.expectedToken(token.ASSIGN) {
 		return nil
 	}
-	// skip until semicolon
-	for !p.curTokenIs(token.SEMICOLON) {
+	if !p.expectValue("let") {
+		return nil
+	}
+	stmt.Value = p.parseExpression(LOWEST)
+	if stmt.Value == nil {
+		return nil
+	}
+	if p.peekTokenIs(token.SEMICOLON) {
 		p.nextToken()
 	}
 
 	return stmt
 }
 
+// expectValue advances to the first token of the value of a let or return
+// statement, reporting an error if the statement ends before a value is given.
+func (p *Parser) expectValue(statement string) bool {
+	if p.peekTokenIs(token.SEMICOLON) || p.peekTokenIs(token.EOF) {
+		p.addError(diag.MissingValue, p.peekToken.Span(), "expected a value in %s statement, got %s instead",
+			statement, p.peekToken.Type)
+		return false
+	}
+	p.nextToken()
+	return true
+}
+
 func (p *Parser) parseExpression(precedence int) ast.Expression {
 	prefix := p.prefixParseFns[p.curToken.Type]
 	if prefix == nil {
-		return nil
+		p.noPrefixParseFnError(p.curToken)
+		bad := &ast.BadExpression{Token: p.curToken}
+		switch p.curToken.Type {
+		case token.RPAREN, token.RBRACE, token.RBRACK:
+			if p.isCloser(p.curToken.Type) {
+				// leave the closing delimiter to the construct it belongs to,
+				// a stray one is skipped like any other token
+				bad.Token.End = bad.Token.Pos
+				p.backup()
+			}
+		}
+		return bad
 	}
 	leftExp := prefix()
+
+	for leftExp != nil && !p.peekTokenIs(token.SEMICOLON) && precedence < p.peekPrecedence() {
+		infix := p.infixParseFns[p.peekToken.Type]
+		if infix == nil {
+			return leftExp
+		}
+		p.nextToken()
+		leftExp = infix(leftExp)
+	}
 	return leftExp
 }
 func (p *Parser) parseExpressionStatement() *ast.ExpressionStatement {
 	stmt := &ast.ExpressionStatement{Token: p.curToken}
 	stmt.Expression = p.parseExpression(LOWEST)
+	if stmt.Expression == nil {
+		return nil
+	}
 	if p.peekTokenIs(token.SEMICOLON) {
 		p.nextToken()
 	}
@@ -176,19 +906,85 @@ func (p *Parser) parseExpressionStatement() *ast.ExpressionStatement {
 }
 func (p *Parser) parseReturnStatement() *ast.ReturnStatement {
 	stmt := &ast.ReturnStatement{Token: p.curToken}
+	if !p.expectValue("return") {
+		return nil
+	}
+	stmt.Value = p.parseExpression(LOWEST)
+	if stmt.Value == nil {
+		return nil
+	}
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+	return stmt
+}
+
+func (p *Parser) parseIfStatement() *ast.IfStatement {
+	stmt := &ast.IfStatement{Token: p.curToken}
 	p.nextToken()
+	stmt.Condition = p.parseExpression(LOWEST)
+	if stmt.Condition == nil {
+		return nil
+	}
+	if !p.expectedToken(token.LBRACE) {
+		return nil
+	}
+	if stmt.Value = p.parseBlockStatement(); stmt.Value == nil {
+		return nil
+	}
 
-	for !p.curTokenIs(token.SEMICOLON) {
+	if !p.peekTokenIs(token.ELSE) {
+		return stmt
+	}
+	p.nextToken()
+	if p.peekTokenIs(token.IF) {
 		p.nextToken()
+		if stmt.ElseIf = p.parseIfStatement(); stmt.ElseIf == nil {
+			return nil
+		}
+		return stmt
+	}
+	if !p.expectedToken(token.LBRACE) {
+		return nil
+	}
+	if stmt.ElseValue = p.parseBlockStatement(); stmt.ElseValue == nil {
+		return nil
 	}
 	return stmt
 }
 
-func (p *Parser) parseIfStatement() *ast.IfStatement {
-	var expr ast.IfStatement
-	return &expr
+func (p *Parser) parseIfExpression() ast.Expression {
+	if expr := p.parseIfStatement(); expr != nil {
+		return expr
+	}
+	return nil
 }
 
-func (p *Parser) parseError(msg string, args ...any) {
-	fmt.Printf(msg, args...)
+func (p *Parser) parseBlockStatement() *ast.BlockStatement {
+	block := &ast.BlockStatement{Token: p.curToken}
+	block.Statements = []ast.Statement{}
+	defer p.openDelimiter(token.RBRACE)()
+	p.nextToken()
+
+	for !p.curTokenIs(token.RBRACE) {
+		if p.errorCount() >= MaxErrors {
+			// ParseProgram reports that it gave up
+			return nil
+		}
+		if p.curTokenIs(token.EOF) {
+			d := diag.Errorf(diag.UnclosedBlock, p.curToken.Span(), "expected %s to close block, got %s instead",
+				token.RBRACE, p.curToken.Type)
+			d.Notes = []string{fmt.Sprintf("the block was opened at %s", block.Token.Pos)}
+			d.Fixes = []diag.Fix{insertFix(p.curToken.Pos, token.RBRACE)}
+			p.report(d)
+			return nil
+		}
+		stmt := p.parseStatementWithRecovery()
+		if stmt != nil {
+			block.Statements = append(block.Statements, stmt)
+		}
+		p.nextToken()
+	}
+	block.Rbrace = p.curToken
+	return block
 }
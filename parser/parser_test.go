@@ -1,9 +1,12 @@
 package parser
 
 import (
+	"fmt"
+	"strings"
 	"testing"
 
 	"ljos.app/interpreter/ast"
+	"ljos.app/interpreter/diag"
 	"ljos.app/interpreter/lexer"
 )
 
@@ -21,49 +24,100 @@ func TestReturnStatements(t *testing.T) {
 		t.Fatalf("program.Statements does not contain exactly 3 statements, got %d", len(program.Statements))
 		return
 	}
+	expectedValues := []int64{5, 10, 730246}
 	for i, stmt := range program.Statements {
 		returnStmt, ok := stmt.(*ast.ReturnStatement)
 		if !ok {
-			t.Errorf("stmt not *ast.ReturnStatement(%d). got %T", i, stmt)
+			t.Fatalf("stmt not *ast.ReturnStatement(%d). got %T", i, stmt)
 		}
 		if returnStmt.TokenLiteral() != "return" {
 			t.Errorf("returnStmt.TokenLiteral not 'return', got %q",
 				returnStmt.TokenLiteral())
 		}
-
+		testIntegerLiteral(t, returnStmt.Value, expectedValues[i])
 	}
 
 }
 func TestLetStatements(t *testing.T) {
+	tests := []struct {
+		input              string
+		expectedIdentifier string
+		expectedValue      any
+	}{
+		{"let x = 5;", "x", 5},
+		{"let y = true;", "y", true},
+		{"let foobar = y;", "foobar", "y"},
+		{"let z = 10", "z", 10},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParseErrors(t, p)
+
+		if program == nil {
+			t.Fatalf("ParseProgram() returned nil")
+		}
+		if len(program.Statements) != 1 {
+			t.Fatalf("program.Statements does not contain 1 statement. got=%d", len(program.Statements))
+		}
+		stmt := program.Statements[0]
+		if !testLetStatements(t, stmt, tt.expectedIdentifier) {
+			return
+		}
+		val := stmt.(*ast.LetStatement).Value
+		if !testLiteralExpression(t, val, tt.expectedValue) {
+			return
+		}
+	}
+}
+
+func TestLetAndReturnWithoutSemicolons(t *testing.T) {
 	input := `
-  let x = 5;
-  let y = 10;
-  let foobar = 75319246;
+  let x = 5 + 5
+  let y = x
+  return x * y
   `
 	l := lexer.New(input)
 	p := New(l)
 	program := p.ParseProgram()
 	checkParseErrors(t, p)
 
-	if program == nil {
-		t.Fatalf("ParseProgram() returned nil")
+	expected := "let x = (5 + 5);let y = x;return (x * y);"
+	if program.String() != expected {
+		t.Fatalf("program.String() wrong. expected=%q, got=%q", expected, program.String())
 	}
+}
 
-	if len(program.Statements) != 3 {
-		t.Fatalf("program.Statements does not contain 3 statements. got=%d", len(program.Statements))
-	}
+func TestMissingValueErrors(t *testing.T) {
 	tests := []struct {
-		expectedIdentifier string
+		input         string
+		expectedError string
 	}{
-		{"x"},
-		{"y"},
-		{"foobar"},
+		{"let x = ;", "expected a value in let statement, got ; instead"},
+		{"let x =", "expected a value in let statement, got EOF instead"},
+		{"return;", "expected a value in return statement, got ; instead"},
+		{"return", "expected a value in return statement, got EOF instead"},
 	}
 
-	for i, tt := range tests {
-		stmt := program.Statements[i]
-		if !testLetStatements(t, stmt, tt.expectedIdentifier) {
-			return
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+
+		if len(program.Statements) != 1 {
+			t.Fatalf("input %q: expected 1 statement, got %d", tt.input, len(program.Statements))
+		}
+		if _, ok := program.Statements[0].(*ast.BadStatement); !ok {
+			t.Errorf("input %q: expected *ast.BadStatement, got %T", tt.input, program.Statements[0])
+		}
+		errors := p.Errors()
+		if len(errors) == 0 {
+			t.Fatalf("input %q: expected a parser error", tt.input)
+		}
+		if !strings.HasPrefix(errors[0].Message, tt.expectedError) {
+			t.Errorf("input %q: wrong error. expected=%q, got=%q", tt.input, tt.expectedError, errors[0].Message)
 		}
 	}
 }
@@ -99,9 +153,8 @@ func checkParseErrors(t *testing.T, p *Parser) {
 	}
 
 	t.Errorf("parser has %d errors", len(errors))
-	for _, msg := range errors {
-		t.Errorf("parser error %q\n", msg.Error)
-		t.Errorf("\n%s", msg.Lines)
+	for _, d := range errors {
+		t.Errorf("parser error %q", d.Error())
 	}
 	t.FailNow()
 }
@@ -129,3 +182,1343 @@ func TestIdentifiers(t *testing.T) {
 	}
 
 }
+
+func TestIntegerLiteralExpression(t *testing.T) {
+	input := "5;"
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParseErrors(t, p)
+
+	if len(program.Statements) != 1 {
+		t.Fatalf("program should contain 1 statement, got %d", len(program.Statements))
+	}
+	stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+	if !ok {
+		t.Fatalf("program.Statements[0] is not ast.ExpressionStatement, got %T", program.Statements[0])
+	}
+	testIntegerLiteral(t, stmt.Expression, 5)
+}
+
+func TestParsingPrefixExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		operator string
+		value    any
+	}{
+		{"!5;", "!", 5},
+		{"-15;", "-", 15},
+		{"!true;", "!", true},
+		{"!false;", "!", false},
+		{"-x;", "-", "x"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParseErrors(t, p)
+
+		if len(program.Statements) != 1 {
+			t.Fatalf("program should contain 1 statement, got %d", len(program.Statements))
+		}
+		stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+		if !ok {
+			t.Fatalf("program.Statements[0] is not ast.ExpressionStatement, got %T", program.Statements[0])
+		}
+		exp, ok := stmt.Expression.(*ast.PrefixExpression)
+		if !ok {
+			t.Fatalf("stmt.Expression is not *ast.PrefixExpression, got %T", stmt.Expression)
+		}
+		if exp.Operator != tt.operator {
+			t.Fatalf("exp.Operator is not %q, got %q", tt.operator, exp.Operator)
+		}
+		if !testLiteralExpression(t, exp.Right, tt.value) {
+			return
+		}
+	}
+}
+
+func TestParsingInfixExpressions(t *testing.T) {
+	tests := []struct {
+		input      string
+		leftValue  any
+		operator   string
+		rightValue any
+	}{
+		{"5 + 5;", 5, "+", 5},
+		{"5 - 5;", 5, "-", 5},
+		{"5 * 5;", 5, "*", 5},
+		{"5 / 5;", 5, "/", 5},
+		{"5 > 5;", 5, ">", 5},
+		{"5 < 5;", 5, "<", 5},
+		{"5 >= 5;", 5, ">=", 5},
+		{"5 <= 5;", 5, "<=", 5},
+		{"5 == 5;", 5, "==", 5},
+		{"5 != 5;", 5, "!=", 5},
+		{"x + y;", "x", "+", "y"},
+		{"true == true", true, "==", true},
+		{"true != false", true, "!=", false},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParseErrors(t, p)
+
+		if len(program.Statements) != 1 {
+			t.Fatalf("program should contain 1 statement, got %d", len(program.Statements))
+		}
+		stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+		if !ok {
+			t.Fatalf("program.Statements[0] is not ast.ExpressionStatement, got %T", program.Statements[0])
+		}
+		if !testInfixExpression(t, stmt.Expression, tt.leftValue, tt.operator, tt.rightValue) {
+			return
+		}
+	}
+}
+
+func TestOperatorPrecedenceParsing(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"-a * b", "((-a) * b)"},
+		{"!-a", "(!(-a))"},
+		{"a + b + c", "((a + b) + c)"},
+		{"a + b - c", "((a + b) - c)"},
+		{"a * b * c", "((a * b) * c)"},
+		{"a * b / c", "((a * b) / c)"},
+		{"a + b / c", "(a + (b / c))"},
+		{"a + b * c + d / e - f", "(((a + (b * c)) + (d / e)) - f)"},
+		{"3 + 4; -5 * 5", "(3 + 4)((-5) * 5)"},
+		{"5 > 4 == 3 < 4", "((5 > 4) == (3 < 4))"},
+		{"5 <= 4 != 3 >= 4", "((5 <= 4) != (3 >= 4))"},
+		{"3 + 4 * 5 == 3 * 1 + 4 * 5", "((3 + (4 * 5)) == ((3 * 1) + (4 * 5)))"},
+		{"5 + 3 * -x", "(5 + (3 * (-x)))"},
+		{"true", "true"},
+		{"3 > 5 == false", "((3 > 5) == false)"},
+		{"1 + (2 + 3) + 4", "((1 + (2 + 3)) + 4)"},
+		{"(5 + 5) * 2", "((5 + 5) * 2)"},
+		{"-(5 + 5)", "(-(5 + 5))"},
+		{"!(true == true)", "(!(true == true))"},
+		{"a % b * c", "((a % b) * c)"},
+		{"a + b % c", "(a + (b % c))"},
+		{"a || b && c", "(a || (b && c))"},
+		{"a && b || c && d", "((a && b) || (c && d))"},
+		{"a == b && c != d", "((a == b) && (c != d))"},
+		{"a | b ^ c & d", "((a | b) ^ (c & d))"},
+		{"a & b == c", "((a & b) == c)"},
+		{"x & 1 == 1", "((x & 1) == 1)"},
+		{"a + b | c * d & e", "((a + b) | ((c * d) & e))"},
+		{"a | b < c ^ d", "((a | b) < (c ^ d))"},
+		{"a << 1 + 2", "(a << (1 + 2))"},
+		{"a < b << c", "(a < (b << c))"},
+		{"a >> b >> c", "((a >> b) >> c)"},
+		{"2 ** 3 ** 2", "(2 ** (3 ** 2))"},
+		{"-2 ** 2", "(-(2 ** 2))"},
+		{"2 ** -1", "(2 ** (-1))"},
+		{"a * b ** c", "(a * (b ** c))"},
+		{"a ** f(b)", "(a ** f(b))"},
+		{"x = a || b", "x = (a || b)"},
+		{"x <<= 1 + 2", "x <<= (1 + 2)"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParseErrors(t, p)
+
+		actual := program.String()
+		if actual != tt.expected {
+			t.Errorf("expected=%q, got=%q", tt.expected, actual)
+		}
+	}
+}
+
+func testIntegerLiteral(t *testing.T, il ast.Expression, value int64) bool {
+	integ, ok := il.(*ast.IntegerLiteral)
+	if !ok {
+		t.Errorf("il not *ast.IntegerLiteral. got=%T", il)
+		return false
+	}
+	if integ.Value != value {
+		t.Errorf("integ.Value not %d. got=%d", value, integ.Value)
+		return false
+	}
+	if integ.TokenLiteral() != fmt.Sprintf("%d", value) {
+		t.Errorf("integ.TokenLiteral not %d. got=%s", value, integ.TokenLiteral())
+		return false
+	}
+	return true
+}
+
+func testIdentifier(t *testing.T, exp ast.Expression, value string) bool {
+	ident, ok := exp.(*ast.Identifier)
+	if !ok {
+		t.Errorf("exp not *ast.Identifier. got=%T", exp)
+		return false
+	}
+	if ident.Value != value {
+		t.Errorf("ident.Value not %s. got=%s", value, ident.Value)
+		return false
+	}
+	if ident.TokenLiteral() != value {
+		t.Errorf("ident.TokenLiteral not %s. got=%s", value, ident.TokenLiteral())
+		return false
+	}
+	return true
+}
+
+func testBooleanLiteral(t *testing.T, exp ast.Expression, value bool) bool {
+	bo, ok := exp.(*ast.Boolean)
+	if !ok {
+		t.Errorf("exp not *ast.Boolean. got=%T", exp)
+		return false
+	}
+	if bo.Value != value {
+		t.Errorf("bo.Value not %t. got=%t", value, bo.Value)
+		return false
+	}
+	if bo.TokenLiteral() != fmt.Sprintf("%t", value) {
+		t.Errorf("bo.TokenLiteral not %t. got=%s", value, bo.TokenLiteral())
+		return false
+	}
+	return true
+}
+
+func testLiteralExpression(t *testing.T, exp ast.Expression, expected any) bool {
+	switch v := expected.(type) {
+	case int:
+		return testIntegerLiteral(t, exp, int64(v))
+	case int64:
+		return testIntegerLiteral(t, exp, v)
+	case string:
+		return testIdentifier(t, exp, v)
+	case bool:
+		return testBooleanLiteral(t, exp, v)
+	}
+	t.Errorf("type of exp not handled. got=%T", exp)
+	return false
+}
+
+func testInfixExpression(t *testing.T, exp ast.Expression, left any, operator string, right any) bool {
+	opExp, ok := exp.(*ast.InfixExpression)
+	if !ok {
+		t.Errorf("exp is not *ast.InfixExpression. got=%T(%s)", exp, exp)
+		return false
+	}
+	if !testLiteralExpression(t, opExp.Left, left) {
+		return false
+	}
+	if opExp.Operator != operator {
+		t.Errorf("exp.Operator is not %q. got=%q", operator, opExp.Operator)
+		return false
+	}
+	if !testLiteralExpression(t, opExp.Right, right) {
+		return false
+	}
+	return true
+}
+
+func TestIfStatement(t *testing.T) {
+	input := `if (x < y) { x }`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParseErrors(t, p)
+
+	if len(program.Statements) != 1 {
+		t.Fatalf("program should contain 1 statement, got %d", len(program.Statements))
+	}
+	stmt, ok := program.Statements[0].(*ast.IfStatement)
+	if !ok {
+		t.Fatalf("program.Statements[0] is not *ast.IfStatement, got %T", program.Statements[0])
+	}
+	if !testInfixExpression(t, stmt.Condition, "x", "<", "y") {
+		return
+	}
+	if len(stmt.Value.Statements) != 1 {
+		t.Fatalf("consequence is not 1 statement, got %d", len(stmt.Value.Statements))
+	}
+	consequence, ok := stmt.Value.Statements[0].(*ast.ExpressionStatement)
+	if !ok {
+		t.Fatalf("stmt.Value.Statements[0] is not *ast.ExpressionStatement, got %T", stmt.Value.Statements[0])
+	}
+	if !testIdentifier(t, consequence.Expression, "x") {
+		return
+	}
+	if stmt.ElseValue != nil || stmt.ElseIf != nil {
+		t.Errorf("stmt should not have an else branch, got %+v", stmt)
+	}
+}
+
+func TestIfElseIfElseStatement(t *testing.T) {
+	input := `
+  if (x < y) {
+    x
+  } else if (x > y) {
+    y
+  } else if x == 1 {
+    1
+  } else {
+    0
+  }
+  `
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParseErrors(t, p)
+
+	if len(program.Statements) != 1 {
+		t.Fatalf("program should contain 1 statement, got %d", len(program.Statements))
+	}
+	stmt, ok := program.Statements[0].(*ast.IfStatement)
+	if !ok {
+		t.Fatalf("program.Statements[0] is not *ast.IfStatement, got %T", program.Statements[0])
+	}
+	if stmt.ElseValue != nil {
+		t.Fatalf("stmt.ElseValue should be nil when an else if follows, got %s", stmt.ElseValue)
+	}
+	second := stmt.ElseIf
+	if second == nil || !testInfixExpression(t, second.Condition, "x", ">", "y") {
+		t.Fatalf("stmt.ElseIf is wrong, got %v", second)
+	}
+	third := second.ElseIf
+	if third == nil || !testInfixExpression(t, third.Condition, "x", "==", 1) {
+		t.Fatalf("stmt.ElseIf.ElseIf is wrong, got %v", third)
+	}
+	if third.ElseIf != nil || third.ElseValue == nil {
+		t.Fatalf("last else if should carry the else branch, got %v", third)
+	}
+
+	expected := "if (x < y) {x} else if (x > y) {y} else if (x == 1) {1} else {0}"
+	if stmt.String() != expected {
+		t.Errorf("stmt.String() wrong. expected=%q, got=%q", expected, stmt.String())
+	}
+}
+
+func TestIfExpression(t *testing.T) {
+	input := `let x = if (a) { 1 } else { 2 };`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParseErrors(t, p)
+
+	if len(program.Statements) != 1 {
+		t.Fatalf("program should contain 1 statement, got %d", len(program.Statements))
+	}
+	letStmt, ok := program.Statements[0].(*ast.LetStatement)
+	if !ok {
+		t.Fatalf("program.Statements[0] is not *ast.LetStatement, got %T", program.Statements[0])
+	}
+	ifExpr, ok := letStmt.Value.(*ast.IfStatement)
+	if !ok {
+		t.Fatalf("letStmt.Value is not *ast.IfStatement, got %T", letStmt.Value)
+	}
+	if !testIdentifier(t, ifExpr.Condition, "a") {
+		return
+	}
+	if ifExpr.ElseValue == nil {
+		t.Fatalf("ifExpr.ElseValue is nil")
+	}
+	if program.String() != "let x = if a {1} else {2};" {
+		t.Errorf("program.String() wrong. got=%q", program.String())
+	}
+}
+
+func TestUnclosedBlockError(t *testing.T) {
+	input := `if (x) { x`
+
+	l := lexer.New(input)
+	p := New(l)
+	p.ParseProgram()
+
+	errors := p.Errors()
+	if len(errors) == 0 {
+		t.Fatalf("expected a parser error for an unclosed block")
+	}
+	if !strings.HasPrefix(errors[0].Message, "expected } to close block, got EOF instead") {
+		t.Errorf("wrong error, got %q", errors[0].Message)
+	}
+}
+
+func TestFunctionLiteralParsing(t *testing.T) {
+	input := `fn(x, y) { x + y; }`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParseErrors(t, p)
+
+	if len(program.Statements) != 1 {
+		t.Fatalf("program should contain 1 statement, got %d", len(program.Statements))
+	}
+	stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+	if !ok {
+		t.Fatalf("program.Statements[0] is not ast.ExpressionStatement, got %T", program.Statements[0])
+	}
+	function, ok := stmt.Expression.(*ast.FunctionLiteral)
+	if !ok {
+		t.Fatalf("stmt.Expression is not *ast.FunctionLiteral, got %T", stmt.Expression)
+	}
+	if len(function.Parameters) != 2 {
+		t.Fatalf("function literal parameters wrong. want 2, got %d", len(function.Parameters))
+	}
+	testLiteralExpression(t, function.Parameters[0], "x")
+	testLiteralExpression(t, function.Parameters[1], "y")
+
+	if len(function.Body.Statements) != 1 {
+		t.Fatalf("function.Body.Statements has not 1 statement, got %d", len(function.Body.Statements))
+	}
+	bodyStmt, ok := function.Body.Statements[0].(*ast.ExpressionStatement)
+	if !ok {
+		t.Fatalf("function body stmt is not ast.ExpressionStatement, got %T", function.Body.Statements[0])
+	}
+	testInfixExpression(t, bodyStmt.Expression, "x", "+", "y")
+}
+
+func TestFunctionParameterParsing(t *testing.T) {
+	tests := []struct {
+		input          string
+		expectedParams []string
+	}{
+		{"fn() {};", []string{}},
+		{"fn(x) {};", []string{"x"}},
+		{"fn(x, y, z) {};", []string{"x", "y", "z"}},
+		{"() => 1;", []string{}},
+		{"(x) => x;", []string{"x"}},
+		{"(x, y, z) => { x };", []string{"x", "y", "z"}},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParseErrors(t, p)
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		function, ok := stmt.Expression.(*ast.FunctionLiteral)
+		if !ok {
+			t.Fatalf("input %q: stmt.Expression is not *ast.FunctionLiteral, got %T", tt.input, stmt.Expression)
+		}
+		if len(function.Parameters) != len(tt.expectedParams) {
+			t.Errorf("input %q: length parameters wrong. want %d, got %d",
+				tt.input, len(tt.expectedParams), len(function.Parameters))
+		}
+		for i, ident := range tt.expectedParams {
+			testLiteralExpression(t, function.Parameters[i], ident)
+		}
+	}
+}
+
+func TestArrowLambdaParsing(t *testing.T) {
+	input := `(x, y) => x + y`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParseErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	lambda, ok := stmt.Expression.(*ast.FunctionLiteral)
+	if !ok {
+		t.Fatalf("stmt.Expression is not *ast.FunctionLiteral, got %T", stmt.Expression)
+	}
+	if !lambda.IsLambda() {
+		t.Errorf("lambda.IsLambda() should be true")
+	}
+	if len(lambda.Body.Statements) != 1 {
+		t.Fatalf("lambda.Body.Statements has not 1 statement, got %d", len(lambda.Body.Statements))
+	}
+	bodyStmt := lambda.Body.Statements[0].(*ast.ExpressionStatement)
+	testInfixExpression(t, bodyStmt.Expression, "x", "+", "y")
+
+	if lambda.String() != "(x, y) => (x + y)" {
+		t.Errorf("lambda.String() wrong, got %q", lambda.String())
+	}
+}
+
+func TestArrowLambdaParameterErrors(t *testing.T) {
+	tests := []struct {
+		input         string
+		expectedError string
+	}{
+		{"(x, 1) => x", "expected lambda parameter to be an identifier, got 1 instead"},
+		{"(x, y);", "expected => after parameter list, got ; instead"},
+		{"();", "expected next token to be =>, got ; instead"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		p.ParseProgram()
+
+		errors := p.Errors()
+		if len(errors) == 0 {
+			t.Fatalf("input %q: expected a parser error", tt.input)
+		}
+		if !strings.HasPrefix(errors[0].Message, tt.expectedError) {
+			t.Errorf("input %q: wrong error. expected=%q, got=%q", tt.input, tt.expectedError, errors[0].Message)
+		}
+	}
+}
+
+func TestCallExpressionParsing(t *testing.T) {
+	input := "add(1, 2 * 3, 4 + 5);"
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParseErrors(t, p)
+
+	if len(program.Statements) != 1 {
+		t.Fatalf("program should contain 1 statement, got %d", len(program.Statements))
+	}
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	exp, ok := stmt.Expression.(*ast.CallExpression)
+	if !ok {
+		t.Fatalf("stmt.Expression is not *ast.CallExpression, got %T", stmt.Expression)
+	}
+	if !testIdentifier(t, exp.Function, "add") {
+		return
+	}
+	if len(exp.Arguments) != 3 {
+		t.Fatalf("wrong length of arguments, got %d", len(exp.Arguments))
+	}
+	testLiteralExpression(t, exp.Arguments[0], 1)
+	testInfixExpression(t, exp.Arguments[1], 2, "*", 3)
+	testInfixExpression(t, exp.Arguments[2], 4, "+", 5)
+}
+
+func TestCallPrecedenceParsing(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"a + add(b * c) + d", "((a + add((b * c))) + d)"},
+		{"add(a, b, 1, 2 * 3, 4 + 5, add(6, 7 * 8))", "add(a, b, 1, (2 * 3), (4 + 5), add(6, (7 * 8)))"},
+		{"add(a + b + c * d / f + g)", "add((((a + b) + ((c * d) / f)) + g))"},
+		{"fn(x) { x }(5)", "fn(x) {x}(5)"},
+		{"((x) => x * 2)(5)", "(x) => (x * 2)(5)"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParseErrors(t, p)
+
+		actual := program.String()
+		if actual != tt.expected {
+			t.Errorf("expected=%q, got=%q", tt.expected, actual)
+		}
+	}
+}
+
+func TestFunctionProgramParsing(t *testing.T) {
+	input := `
+  let five = 5;
+  let ten = 10;
+
+  let add = fn(x, y) {
+    x + y;
+  };
+
+  let result = add(five, ten);
+  `
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParseErrors(t, p)
+
+	expected := "let five = 5;let ten = 10;let add = fn(x, y) {(x + y)};let result = add(five, ten);"
+	if program.String() != expected {
+		t.Errorf("program.String() wrong. expected=%q, got=%q", expected, program.String())
+	}
+}
+
+func TestNodePositions(t *testing.T) {
+	input := `let add = fn(x, y) {
+  x + y;
+};
+add(1, 2 * 3);
+let f = (a) => -a;`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParseErrors(t, p)
+
+	tests := []struct {
+		node     ast.Node
+		pos, end string
+	}{
+		{program, "1:1", "5:18"},
+		{program.Statements[0], "1:1", "3:2"},
+		{program.Statements[0].(*ast.LetStatement).Value, "1:11", "3:2"},
+		{program.Statements[1], "4:1", "4:14"},
+		{program.Statements[1].(*ast.ExpressionStatement).Expression.(*ast.CallExpression).Arguments[1], "4:8", "4:13"},
+		{program.Statements[2].(*ast.LetStatement).Value, "5:9", "5:18"},
+	}
+
+	for i, tt := range tests {
+		if tt.node.Pos().String() != tt.pos {
+			t.Errorf("tests[%d] %q - pos wrong. expected=%s, got=%s", i, tt.node, tt.pos, tt.node.Pos())
+		}
+		if tt.node.End().String() != tt.end {
+			t.Errorf("tests[%d] %q - end wrong. expected=%s, got=%s", i, tt.node, tt.end, tt.node.End())
+		}
+	}
+}
+
+func TestErrorLineUsesOffendingToken(t *testing.T) {
+	input := "let x = 5;\nlet\n\n= 10;"
+
+	l := lexer.New(input)
+	p := New(l)
+	p.ParseProgram()
+
+	errors := p.Errors()
+	if len(errors) == 0 {
+		t.Fatalf("expected a parser error")
+	}
+	expected := "4:1: error[P0001]: expected next token to be IDENTIFIER, got = instead"
+	if errors[0].Error() != expected {
+		t.Errorf("wrong error. expected=%q, got=%q", expected, errors[0].Error())
+	}
+}
+
+func TestDiagnostics(t *testing.T) {
+	tests := []struct {
+		input        string
+		expected     []string
+		expectedFix  string
+		expectedNote string
+	}{
+		{"let x = @;", []string{"1:9: error[L0001]: illegal character \"@\""}, "", ""},
+		{"add(1, 2;", []string{"1:9: error[P0001]: expected next token to be ), got ; instead"}, "insert \")\"", ""},
+		{"let x = 5 +;", []string{"1:12: error[P0002]: expected an expression, got ; instead"}, "", ""},
+		{"if (x) {\n  x", []string{"2:4: error[P0006]: expected } to close block, got EOF instead"}, "insert \"}\"", "the block was opened at 1:8"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		p.ParseProgram()
+
+		errors := p.Errors()
+		if len(errors) < len(tt.expected) {
+			t.Fatalf("input %q: expected %d errors, got %d", tt.input, len(tt.expected), len(errors))
+		}
+		for i, expected := range tt.expected {
+			if errors[i].Error() != expected {
+				t.Errorf("input %q: wrong error. expected=%q, got=%q", tt.input, expected, errors[i].Error())
+			}
+		}
+		if tt.expectedFix != "" && (len(errors[0].Fixes) != 1 || errors[0].Fixes[0].Message != tt.expectedFix) {
+			t.Errorf("input %q: wrong fix. expected=%q, got=%+v", tt.input, tt.expectedFix, errors[0].Fixes)
+		}
+		if tt.expectedNote != "" && (len(errors[0].Notes) != 1 || errors[0].Notes[0] != tt.expectedNote) {
+			t.Errorf("input %q: wrong note. expected=%q, got=%+v", tt.input, tt.expectedNote, errors[0].Notes)
+		}
+	}
+}
+
+func TestErrorRecovery(t *testing.T) {
+	input := `
+  let x 5;
+  let y = 10;
+  let = 3;
+  add(1, );
+  let f = fn(a) {
+    let b = a +;
+    b
+  };
+  if (y > ) { y }
+  return y;
+  `
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+
+	expectedErrors := []string{
+		"2:9: error[P0001]: expected next token to be =, got INT instead",
+		"4:7: error[P0001]: expected next token to be IDENTIFIER, got = instead",
+		"5:10: error[P0002]: expected an expression, got ) instead",
+		"7:16: error[P0002]: expected an expression, got ; instead",
+		"10:11: error[P0002]: expected an expression, got ) instead",
+	}
+	errors := p.Errors()
+	if len(errors) != len(expectedErrors) {
+		for _, d := range errors {
+			t.Log(d.Error())
+		}
+		t.Fatalf("expected %d errors, got %d", len(expectedErrors), len(errors))
+	}
+	for i, expected := range expectedErrors {
+		if errors[i].Error() != expected {
+			t.Errorf("errors[%d] wrong. expected=%q, got=%q", i, expected, errors[i].Error())
+		}
+	}
+
+	expectedStatements := []string{
+		"<bad statement>",
+		"let y = 10;",
+		"<bad statement>",
+		"add(1, <bad expression>)",
+		"let f = fn(a) {let b = (a + <bad expression>);b};",
+		"if (y > <bad expression>) {y}",
+		"return y;",
+	}
+	if len(program.Statements) != len(expectedStatements) {
+		t.Fatalf("expected %d statements, got %d: %q", len(expectedStatements), len(program.Statements), program.String())
+	}
+	for i, expected := range expectedStatements {
+		if program.Statements[i].String() != expected {
+			t.Errorf("statements[%d] wrong. expected=%q, got=%q", i, expected, program.Statements[i].String())
+		}
+	}
+}
+
+func TestErrorRecoveryInsideBlock(t *testing.T) {
+	input := `if (x) { 5 + } let y = 1;`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+
+	errors := p.Errors()
+	if len(errors) != 1 {
+		t.Fatalf("expected 1 error, got %d: %v", len(errors), errors)
+	}
+	if program.String() != "if x {(5 + <bad expression>)}let y = 1;" {
+		t.Errorf("program.String() wrong, got %q", program.String())
+	}
+}
+
+func TestTooManyErrors(t *testing.T) {
+	input := strings.Repeat("let = 1;\n", MaxErrors+5)
+
+	l := lexer.New(input)
+	p := New(l)
+	p.ParseProgram()
+
+	errors := p.Errors()
+	if len(errors) != MaxErrors+1 {
+		t.Fatalf("expected %d errors, got %d", MaxErrors+1, len(errors))
+	}
+	last := errors[len(errors)-1]
+	if last.Code != diag.TooManyErrors {
+		t.Errorf("last error should be %s, got %s", diag.TooManyErrors, last.Error())
+	}
+}
+
+func TestTooManyErrorsCountsLexerErrors(t *testing.T) {
+	tests := []string{
+		"let x = 1;\n" + strings.Repeat("@", 500),
+		strings.Repeat("let x = @;\n", 50),
+		strings.Repeat("let = 1; @\n", 50),
+	}
+
+	for _, input := range tests {
+		p := New(lexer.New(input))
+		p.ParseProgram()
+
+		errors := p.Errors()
+		if len(errors) != MaxErrors+1 {
+			t.Fatalf("expected %d errors, got %d", MaxErrors+1, len(errors))
+		}
+		last := errors[len(errors)-1]
+		if last.Code != diag.TooManyErrors {
+			t.Errorf("last error should be %s, got %s", diag.TooManyErrors, last.Error())
+		}
+	}
+}
+
+func TestTooManyErrorsInsideBlock(t *testing.T) {
+	input := "let f = fn() {\n" + strings.Repeat("let = 1;\n", MaxErrors+5) + "};"
+
+	l := lexer.New(input)
+	p := New(l)
+	p.ParseProgram()
+
+	errors := p.Errors()
+	if len(errors) != MaxErrors+1 {
+		t.Fatalf("expected %d errors, got %d: %v", MaxErrors+1, len(errors), errors)
+	}
+	last := errors[len(errors)-1]
+	if last.Code != diag.TooManyErrors {
+		t.Errorf("last error should be %s, got %s", diag.TooManyErrors, last.Error())
+	}
+}
+
+// Closing delimiters that no enclosing construct is waiting for are skipped,
+// rather than left for a block or the program to parse again.
+func TestStrayClosingDelimiters(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{"}", []string{"1:1: error[P0002]: expected an expression, got } instead"}},
+		{"if (true) { 1; ]", []string{
+			"1:16: error[P0002]: expected an expression, got ] instead",
+			"1:17: error[P0006]: expected } to close block, got EOF instead",
+		}},
+		{"let f = fn() { let x = 1; ) };", []string{"1:27: error[P0002]: expected an expression, got ) instead"}},
+		{"fn(x { x }", []string{
+			"1:6: error[P0001]: expected next token to be ), got { instead",
+			"1:10: error[P0002]: expected an expression, got } instead",
+		}},
+		{"(fn() { 1; ) })", []string{"1:12: error[P0002]: expected an expression, got ) instead"}},
+		{"[1, ); 2", []string{"1:5: error[P0002]: expected an expression, got ) instead"}},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		p.ParseProgram()
+
+		errors := p.Errors()
+		if len(errors) != len(tt.expected) {
+			t.Errorf("input %q: expected %d errors, got %v", tt.input, len(tt.expected), errors)
+			continue
+		}
+		for i, expected := range tt.expected {
+			if errors[i].Error() != expected {
+				t.Errorf("input %q: errors[%d] wrong. expected=%q, got=%q", tt.input, i, expected, errors[i].Error())
+			}
+		}
+	}
+}
+
+func TestStringLiteralExpression(t *testing.T) {
+	input := `"hello\tworld";`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParseErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	literal, ok := stmt.Expression.(*ast.StringLiteral)
+	if !ok {
+		t.Fatalf("exp not *ast.StringLiteral. got=%T", stmt.Expression)
+	}
+	if literal.Value != "hello\tworld" {
+		t.Errorf("literal.Value not %q. got=%q", "hello\tworld", literal.Value)
+	}
+	if literal.String() != `"hello\tworld"` {
+		t.Errorf("literal.String() wrong. got=%q", literal.String())
+	}
+}
+
+func TestProgramComments(t *testing.T) {
+	input := `// adds two numbers
+let add = fn(x, y) { x + y }; /* not part of the tree */`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParseErrors(t, p)
+
+	if program.String() != "let add = fn(x, y) {(x + y)};" {
+		t.Errorf("program.String() wrong. got=%q", program.String())
+	}
+	if len(program.Comments) != 2 {
+		t.Fatalf("expected 2 comments, got %d", len(program.Comments))
+	}
+	if program.Comments[0].Text() != " adds two numbers" {
+		t.Errorf("Comments[0].Text() wrong. got=%q", program.Comments[0].Text())
+	}
+	if program.Comments[1].Text() != " not part of the tree " {
+		t.Errorf("Comments[1].Text() wrong. got=%q", program.Comments[1].Text())
+	}
+	if program.Comments[1].Pos().String() != "2:31" {
+		t.Errorf("Comments[1].Pos() wrong. got=%s", program.Comments[1].Pos())
+	}
+}
+
+func TestNumberLiteralValues(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected any
+	}{
+		{"1_000_000", int64(1000000)},
+		{"0xff", int64(255)},
+		{"0XFF_FF", int64(65535)},
+		{"0b1010", int64(10)},
+		{"0o17", int64(15)},
+		{"007", int64(7)},
+		{"3.14", 3.14},
+		{"1e9", 1e9},
+		{"2.5E-3", 2.5e-3},
+		{"1_000.000_1", 1000.0001},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParseErrors(t, p)
+
+		exp := program.Statements[0].(*ast.ExpressionStatement).Expression
+		switch expected := tt.expected.(type) {
+		case int64:
+			lit, ok := exp.(*ast.IntegerLiteral)
+			if !ok {
+				t.Errorf("input %s: exp not *ast.IntegerLiteral. got=%T", tt.input, exp)
+				continue
+			}
+			if lit.Value != expected {
+				t.Errorf("input %s: wrong value. expected=%d, got=%d", tt.input, expected, lit.Value)
+			}
+		case float64:
+			lit, ok := exp.(*ast.FloatLiteral)
+			if !ok {
+				t.Errorf("input %s: exp not *ast.FloatLiteral. got=%T", tt.input, exp)
+				continue
+			}
+			if lit.Value != expected {
+				t.Errorf("input %s: wrong value. expected=%g, got=%g", tt.input, expected, lit.Value)
+			}
+		}
+	}
+}
+
+func TestIntegerOverflow(t *testing.T) {
+	l := lexer.New("99999999999999999999")
+	p := New(l)
+	p.ParseProgram()
+
+	errors := p.Errors()
+	if len(errors) != 1 {
+		t.Fatalf("expected 1 error, got %d", len(errors))
+	}
+	expected := `1:1: error[P0004]: could not parse "99999999999999999999" as integer`
+	if errors[0].Error() != expected {
+		t.Errorf("wrong error. expected=%q, got=%q", expected, errors[0].Error())
+	}
+}
+
+func TestAssignExpressionParsing(t *testing.T) {
+	tests := []struct {
+		input    string
+		operator string
+		expected string
+	}{
+		{"x = 5;", "=", "x = 5"},
+		{"x += 1 * 2;", "+=", "x += (1 * 2)"},
+		{"x -= y;", "-=", "x -= y"},
+		{"x *= -2;", "*=", "x *= (-2)"},
+		{"x /= 2;", "/=", "x /= 2"},
+		{"x %= 3;", "%=", "x %= 3"},
+		{"x = y = 1;", "=", "x = y = 1"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParseErrors(t, p)
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		assign, ok := stmt.Expression.(*ast.AssignExpression)
+		if !ok {
+			t.Fatalf("input %s: exp not *ast.AssignExpression. got=%T", tt.input, stmt.Expression)
+		}
+		if !testIdentifier(t, assign.Target, "x") {
+			return
+		}
+		if assign.Operator != tt.operator {
+			t.Errorf("input %s: wrong operator. expected=%q, got=%q", tt.input, tt.operator, assign.Operator)
+		}
+		if assign.String() != tt.expected {
+			t.Errorf("input %s: wrong String(). expected=%q, got=%q", tt.input, tt.expected, assign.String())
+		}
+	}
+
+	l := lexer.New("x = y = 1;")
+	program := New(l).ParseProgram()
+	outer := program.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.AssignExpression)
+	if _, ok := outer.Value.(*ast.AssignExpression); !ok {
+		t.Errorf("assignment should be right associative, got value %T", outer.Value)
+	}
+}
+
+func TestInvalidAssignmentTarget(t *testing.T) {
+	l := lexer.New("a + b = 3;")
+	p := New(l)
+	p.ParseProgram()
+
+	errors := p.Errors()
+	if len(errors) != 1 {
+		t.Fatalf("expected 1 error, got %d", len(errors))
+	}
+	expected := "1:1: error[P0008]: cannot assign to (a + b)"
+	if errors[0].Error() != expected {
+		t.Errorf("wrong error. expected=%q, got=%q", expected, errors[0].Error())
+	}
+}
+
+func TestParsingArrayLiterals(t *testing.T) {
+	input := "[1, 2 * 2, 3 + 3]"
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParseErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	array, ok := stmt.Expression.(*ast.ArrayLiteral)
+	if !ok {
+		t.Fatalf("exp not ast.ArrayLiteral. got=%T", stmt.Expression)
+	}
+	if len(array.Elements) != 3 {
+		t.Fatalf("len(array.Elements) not 3. got=%d", len(array.Elements))
+	}
+	testIntegerLiteral(t, array.Elements[0], 1)
+	testInfixExpression(t, array.Elements[1], 2, "*", 2)
+	testInfixExpression(t, array.Elements[2], 3, "+", 3)
+	if array.End().Column != 18 {
+		t.Errorf("array should end after the ], got column %d", array.End().Column)
+	}
+
+	program = New(lexer.New("[]")).ParseProgram()
+	array = program.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.ArrayLiteral)
+	if len(array.Elements) != 0 {
+		t.Errorf("len(array.Elements) not 0. got=%d", len(array.Elements))
+	}
+}
+
+func TestParsingHashLiterals(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`{}`, `{}`},
+		{`{"one": 1, "two": 2, "three": 3}`, `{"one": 1, "two": 2, "three": 3}`},
+		{`{1: true, false: "f"}`, `{1: true, false: "f"}`},
+		{`{"one": 0 + 1, "two": 10 - 8}`, `{"one": (0 + 1), "two": (10 - 8)}`},
+		{`{"nested": {"a": [1]}}`, `{"nested": {"a": [1]}}`},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParseErrors(t, p)
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		hash, ok := stmt.Expression.(*ast.HashLiteral)
+		if !ok {
+			t.Fatalf("input %s: exp is not ast.HashLiteral. got=%T", tt.input, stmt.Expression)
+		}
+		if hash.String() != tt.expected {
+			t.Errorf("input %s: wrong String(). expected=%q, got=%q", tt.input, tt.expected, hash.String())
+		}
+	}
+}
+
+func TestParsingIndexExpressions(t *testing.T) {
+	input := "myArray[1 + 1]"
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParseErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	indexExp, ok := stmt.Expression.(*ast.IndexExpression)
+	if !ok {
+		t.Fatalf("exp not *ast.IndexExpression. got=%T", stmt.Expression)
+	}
+	if !testIdentifier(t, indexExp.Left, "myArray") {
+		return
+	}
+	testInfixExpression(t, indexExp.Index, 1, "+", 1)
+}
+
+func TestParsingSliceExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"xs[1:3]", "(xs[1:3])"},
+		{"xs[1:]", "(xs[1:])"},
+		{"xs[:3]", "(xs[:3])"},
+		{"xs[:]", "(xs[:])"},
+		{"xs[a + 1:len(xs) - 1]", "(xs[(a + 1):(len(xs) - 1)])"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParseErrors(t, p)
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		slice, ok := stmt.Expression.(*ast.SliceExpression)
+		if !ok {
+			t.Fatalf("input %s: exp not *ast.SliceExpression. got=%T", tt.input, stmt.Expression)
+		}
+		if slice.String() != tt.expected {
+			t.Errorf("input %s: wrong String(). expected=%q, got=%q", tt.input, tt.expected, slice.String())
+		}
+	}
+}
+
+func TestIndexPrecedence(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"a * [1, 2, 3, 4][b * c] * d", "((a * ([1, 2, 3, 4][(b * c)])) * d)"},
+		{"add(a * b[2], b[1], 2 * [1, 2][1])", "add((a * (b[2])), (b[1]), (2 * ([1, 2][1])))"},
+		{"-xs[0]", "(-(xs[0]))"},
+		{"m[\"k\"][0]", "((m[\"k\"])[0])"},
+		{"fs[0](1)", "(fs[0])(1)"},
+		{"xs[0] = 1", "(xs[0]) = 1"},
+		{"m[\"k\"] += 1", "(m[\"k\"]) += 1"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParseErrors(t, p)
+
+		if program.String() != tt.expected {
+			t.Errorf("expected=%q, got=%q", tt.expected, program.String())
+		}
+	}
+}
+
+func TestCollectionParseErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"[1, 2", "1:6: error[P0001]: expected next token to be ], got EOF instead"},
+		{`{"a" 1}`, "1:6: error[P0001]: expected next token to be :, got INT instead"},
+		{`{"a": 1 "b": 2}`, "1:9: error[P0001]: expected next token to be ,, got STRING instead"},
+		{"xs[1", "1:5: error[P0001]: expected next token to be ], got EOF instead"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		p.ParseProgram()
+
+		errors := p.Errors()
+		if len(errors) == 0 {
+			t.Errorf("input %s: expected an error", tt.input)
+			continue
+		}
+		if errors[0].Error() != tt.expected {
+			t.Errorf("input %s: wrong error. expected=%q, got=%q", tt.input, tt.expected, errors[0].Error())
+		}
+	}
+}
+
+func TestMemberExpressionParsing(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"obj.field", "(obj.field)"},
+		{"a.b.c", "((a.b).c)"},
+		{"xs.push(4)", "(xs.push)(4)"},
+		{`"abc".len()`, `("abc".len)()`},
+		{"xs.map(f).filter(g).len()", "(((xs.map)(f).filter)(g).len)()"},
+		{"-a.b", "(-(a.b))"},
+		{"a.b * c.d", "((a.b) * (c.d))"},
+		{"a.b[0]", "((a.b)[0])"},
+		{"m.count += 1", "(m.count) += 1"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParseErrors(t, p)
+
+		if program.String() != tt.expected {
+			t.Errorf("expected=%q, got=%q", tt.expected, program.String())
+		}
+	}
+
+	program := New(lexer.New("xs.len")).ParseProgram()
+	member, ok := program.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.MemberExpression)
+	if !ok {
+		t.Fatalf("exp not *ast.MemberExpression. got=%T", program.Statements[0])
+	}
+	testIdentifier(t, member.Object, "xs")
+	testIdentifier(t, member.Property, "len")
+	if member.End().Column != 7 {
+		t.Errorf("member expression should end after the property, got column %d", member.End().Column)
+	}
+}
+
+func TestMemberExpressionErrors(t *testing.T) {
+	l := lexer.New("xs.1")
+	p := New(l)
+	p.ParseProgram()
+
+	errors := p.Errors()
+	if len(errors) == 0 {
+		t.Fatalf("expected an error")
+	}
+	expected := "1:4: error[P0001]: expected next token to be IDENTIFIER, got INT instead"
+	if errors[0].Error() != expected {
+		t.Errorf("wrong error. expected=%q, got=%q", expected, errors[0].Error())
+	}
+}
+
+func TestWhileStatement(t *testing.T) {
+	input := `while (x < 10) { x += 1; }`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParseErrors(t, p)
+
+	if len(program.Statements) != 1 {
+		t.Fatalf("program.Statements does not contain 1 statement. got=%d", len(program.Statements))
+	}
+	stmt, ok := program.Statements[0].(*ast.WhileStatement)
+	if !ok {
+		t.Fatalf("stmt is not *ast.WhileStatement. got=%T", program.Statements[0])
+	}
+	if !testInfixExpression(t, stmt.Condition, "x", "<", 10) {
+		return
+	}
+	if len(stmt.Body.Statements) != 1 {
+		t.Errorf("body is not 1 statement. got=%d", len(stmt.Body.Statements))
+	}
+	if stmt.String() != "while (x < 10) {x += 1}" {
+		t.Errorf("wrong String(). got=%q", stmt.String())
+	}
+}
+
+func TestForStatement(t *testing.T) {
+	input := `for (x in [1, 2]) { puts(x); };`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParseErrors(t, p)
+
+	if len(program.Statements) != 1 {
+		t.Fatalf("program.Statements does not contain 1 statement. got=%d", len(program.Statements))
+	}
+	stmt, ok := program.Statements[0].(*ast.ForStatement)
+	if !ok {
+		t.Fatalf("stmt is not *ast.ForStatement. got=%T", program.Statements[0])
+	}
+	if !testIdentifier(t, stmt.Variable, "x") {
+		return
+	}
+	if _, ok := stmt.Iterable.(*ast.ArrayLiteral); !ok {
+		t.Errorf("iterable is not *ast.ArrayLiteral. got=%T", stmt.Iterable)
+	}
+	if stmt.String() != "for (x in [1, 2]) {puts(x)}" {
+		t.Errorf("wrong String(). got=%q", stmt.String())
+	}
+}
+
+func TestBreakAndContinue(t *testing.T) {
+	input := `while (true) { if (a) { break; } for (x in xs) { continue } }`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParseErrors(t, p)
+
+	loop := program.Statements[0].(*ast.WhileStatement)
+	ifStmt := loop.Body.Statements[0].(*ast.IfStatement)
+	if _, ok := ifStmt.Value.Statements[0].(*ast.BreakStatement); !ok {
+		t.Errorf("expected *ast.BreakStatement. got=%T", ifStmt.Value.Statements[0])
+	}
+	forStmt := loop.Body.Statements[1].(*ast.ForStatement)
+	if _, ok := forStmt.Body.Statements[0].(*ast.ContinueStatement); !ok {
+		t.Errorf("expected *ast.ContinueStatement. got=%T", forStmt.Body.Statements[0])
+	}
+}
+
+func TestLoopParseErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"break;", "1:1: error[P0009]: break outside of a loop"},
+		{"if (a) { continue }", "1:10: error[P0009]: continue outside of a loop"},
+		{"while (a) { let f = fn() { break; }; }", "1:28: error[P0009]: break outside of a loop"},
+		{"while (a) { let f = () => { continue }; }", "1:29: error[P0009]: continue outside of a loop"},
+		{"for x in xs { }", "1:5: error[P0001]: expected next token to be (, got IDENTIFIER instead"},
+		{"for (x of xs) { }", "1:8: error[P0001]: expected next token to be IN, got IDENTIFIER instead"},
+		{"while (a) x += 1", "1:11: error[P0001]: expected next token to be {, got IDENTIFIER instead"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		p.ParseProgram()
+
+		errors := p.Errors()
+		if len(errors) == 0 {
+			t.Errorf("input %s: expected an error", tt.input)
+			continue
+		}
+		if errors[0].Error() != tt.expected {
+			t.Errorf("input %s: wrong error. expected=%q, got=%q", tt.input, tt.expected, errors[0].Error())
+		}
+	}
+}
+
+func TestIncompleteInput(t *testing.T) {
+	tests := []struct {
+		input      string
+		incomplete bool
+	}{
+		{"let x = 5;", false},
+		{"", false},
+		{"let add = fn(a, b) {", true},
+		{"let add = fn(a, b) {\n  a + b\n", true},
+		{"let add = fn(a, b) {\n  a + b\n}", false},
+		{"add(1,", true},
+		{"(1 + 2", true},
+		{"[1, 2", true},
+		{`{"a": 1,`, true},
+		{"let x = 1 +", true},
+		{"let x =", true},
+		{"if (x) { 1 } else", true},
+		{"while (x) {\n  if (y) {\n", true},
+		{`let s = "multi`, true},
+		{"/* unterminated", true},
+		{"let x = 5 5 5 +", true},
+		{"let = 5; fn(", false},
+		{"let x = );", false},
+		{"1)", false},
+		{"{ 1 }}", false},
+		{"let x = @ {", false},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		p.ParseProgram()
+
+		if p.Incomplete() != tt.incomplete {
+			t.Errorf("input %q: expected Incomplete()=%t, got %t (errors: %v)", tt.input, tt.incomplete, p.Incomplete(), p.Errors())
+		}
+	}
+}
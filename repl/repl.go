@@ -2,28 +2,275 @@ package repl
 
 import (
 	"bufio"
+	"errors"
 	"fmt"
 	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"time"
 
+	"ljos.app/interpreter/ast"
+	"ljos.app/interpreter/diag"
+	"ljos.app/interpreter/evaluator"
 	"ljos.app/interpreter/lexer"
+	"ljos.app/interpreter/lineedit"
+	"ljos.app/interpreter/object"
+	"ljos.app/interpreter/parser"
 	token "ljos.app/interpreter/token"
 )
 
 const PROMPT = ">> "
 
-func Start(in io.ReadCloser, out io.Writer) {
-	scanner := bufio.NewScanner(in)
+// CONTINUATION_PROMPT is shown instead of PROMPT while the lines entered so
+// far are an incomplete statement, such as a function missing its }.
+const CONTINUATION_PROMPT = ".. "
+
+// session is the state kept across the inputs of one REPL run.
+type session struct {
+	env    *object.Environment
+	out    io.Writer
+	errOut io.Writer
+}
+
+// command is a meta-command like `:tokens let x = 1`, called with the text
+// following the command name.
+type command struct {
+	run   func(s *session, arg string)
+	usage string // the argument the command expects, if any
+	help  string
+}
+
+// commands is filled in init since :help lists the commands.
+var commands map[string]command
+
+func init() {
+	commands = map[string]command{
+		":tokens": {(*session).tokens, "<src>", "print the tokens of src"},
+		":ast":    {(*session).ast, "<src>", "print the syntax tree of src"},
+		":env":    {(*session).listEnv, "", "list the bindings of the environment"},
+		":type":   {(*session).typeOf, "<expr>", "evaluate expr and print the type of its value"},
+		":load":   {(*session).load, "<file>", "evaluate the contents of file"},
+		":reset":  {(*session).reset, "", "discard all bindings"},
+		":time":   {(*session).time, "<expr>", "evaluate expr and print how long it took"},
+		":help":   {(*session).help, "", "list the commands"},
+	}
+}
+
+// HISTORY_FILE is the name of the file in the home directory that the
+// history of interactive sessions is kept in.
+const HISTORY_FILE = ".hualang_history"
+
+// lineReader reads the lines of input, showing prompt first.
+type lineReader interface {
+	ReadLine(prompt string) (string, error)
+}
+
+// scannerReader reads plain lines, used when the input is not a terminal.
+type scannerReader struct {
+	scanner *bufio.Scanner
+	out     io.Writer
+}
+
+func (r *scannerReader) ReadLine(prompt string) (string, error) {
+	fmt.Fprint(r.out, prompt)
+	if !r.scanner.Scan() {
+		if err := r.scanner.Err(); err != nil {
+			return "", err
+		}
+		return "", io.EOF
+	}
+	return r.scanner.Text(), nil
+}
+
+// Start reads inputs from in and evaluates them against a single environment,
+// so that bindings persist from one input to the next. Results are printed
+// to out and diagnostics to errOut.
+//
+// When in is a terminal lines are read with a line editor, with history
+// kept in HISTORY_FILE and tab completion of keywords and bound names.
+func Start(in io.ReadCloser, out io.Writer, errOut io.Writer) {
+	s := &session{env: evaluator.NewGlobalEnvironment(out), out: out, errOut: errOut}
+	var lines lineReader = &scannerReader{scanner: bufio.NewScanner(in), out: out}
+	var editor *lineedit.Editor
+	if f, ok := in.(*os.File); ok && lineedit.IsTerminal(f) {
+		editor = lineedit.New(f, out)
+		editor.Complete = s.complete
+		if home, err := os.UserHomeDir(); err == nil {
+			if err := editor.SetHistoryFile(filepath.Join(home, HISTORY_FILE)); err != nil {
+				fmt.Fprintln(errOut, err)
+			}
+		}
+		lines = editor
+	}
+	var input strings.Builder
 
 	for {
-		fmt.Printf(PROMPT)
-		scanned := scanner.Scan()
-		if !scanned {
+		prompt := PROMPT
+		if input.Len() != 0 {
+			prompt = CONTINUATION_PROMPT
+		}
+		line, err := lines.ReadLine(prompt)
+		if errors.Is(err, lineedit.ErrInterrupted) {
+			// Ctrl-C discards the input entered so far
+			input.Reset()
+			continue
+		}
+		if err != nil {
+			if input.Len() != 0 {
+				// report what is missing from the unfinished input
+				s.eval(input.String())
+			}
 			return
 		}
-		line := scanner.Text()
-		l := lexer.New(line)
-		for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
-			fmt.Printf("%+v\n", tok)
+		if editor != nil {
+			if err := editor.AddHistory(line); err != nil {
+				fmt.Fprintln(errOut, err)
+			}
 		}
+		if input.Len() == 0 && strings.HasPrefix(line, ":") {
+			s.runCommand(line)
+			continue
+		}
+
+		input.WriteString(line)
+		input.WriteString("\n")
+		if incomplete(input.String()) {
+			continue
+		}
+		s.eval(input.String())
+		input.Reset()
 	}
 }
+
+// complete returns the keywords and bound names starting with word.
+func (s *session) complete(word string) []string {
+	var candidates []string
+	for _, name := range append(token.Keywords(), s.env.Names()...) {
+		if strings.HasPrefix(name, word) && !slices.Contains(candidates, name) {
+			candidates = append(candidates, name)
+		}
+	}
+	sort.Strings(candidates)
+	return candidates
+}
+
+func (s *session) runCommand(line string) {
+	name, arg, _ := strings.Cut(line, " ")
+	cmd, ok := commands[name]
+	if !ok {
+		fmt.Fprintf(s.errOut, "unknown command %s, see :help\n", name)
+		return
+	}
+	arg = strings.TrimSpace(arg)
+	if cmd.usage != "" && arg == "" {
+		fmt.Fprintf(s.errOut, "usage: %s %s\n", name, cmd.usage)
+		return
+	}
+	cmd.run(s, arg)
+}
+
+// eval parses and evaluates input, printing the result unless it is null.
+func (s *session) eval(input string) {
+	if result := s.evalFile("", input); result != nil && result != evaluator.NULL {
+		fmt.Fprintln(s.out, result.Inspect())
+	}
+}
+
+// evalFile parses and evaluates src as if read from file. Diagnostics are
+// printed to errOut, in which case nil is returned.
+func (s *session) evalFile(file string, src string) object.Object {
+	p := parser.New(lexer.NewFile(file, src))
+	program := p.ParseProgram()
+	renderer := diag.NewRenderer(src)
+	if errors := p.Errors(); len(errors) != 0 {
+		renderer.Render(s.errOut, errors...)
+		return nil
+	}
+
+	evaluated := evaluator.Eval(program, s.env)
+	if err, ok := evaluated.(*object.Error); ok {
+		renderer.Render(s.errOut, err.Diagnostic())
+		return nil
+	}
+	return evaluated
+}
+
+// tokens prints the tokens of arg instead of evaluating it.
+func (s *session) tokens(arg string) {
+	l := lexer.New(arg)
+	for tok := range l.All() {
+		fmt.Fprintf(s.out, "%+v\n", tok)
+	}
+	diag.NewRenderer(arg).Render(s.errOut, l.Errors()...)
+}
+
+// ast prints the syntax tree of arg instead of evaluating it.
+func (s *session) ast(arg string) {
+	p := parser.New(lexer.New(arg))
+	program := p.ParseProgram()
+	ast.Dump(s.out, program)
+	diag.NewRenderer(arg).Render(s.errOut, p.Errors()...)
+}
+
+// listEnv prints the bindings made in the session, leaving out builtins.
+func (s *session) listEnv(string) {
+	for _, name := range s.env.Names() {
+		val, _ := s.env.Get(name)
+		if _, ok := val.(*object.Builtin); !ok {
+			fmt.Fprintf(s.out, "%s = %s\n", name, val.Inspect())
+		}
+	}
+}
+
+func (s *session) typeOf(arg string) {
+	if result := s.evalFile("", arg); result != nil {
+		fmt.Fprintln(s.out, result.Type())
+	}
+}
+
+// load evaluates a source file in the current environment, so that its
+// bindings can be used from the prompt.
+func (s *session) load(arg string) {
+	src, err := os.ReadFile(arg)
+	if err != nil {
+		fmt.Fprintln(s.errOut, err)
+		return
+	}
+	s.evalFile(arg, string(src))
+}
+
+func (s *session) reset(string) {
+	s.env = evaluator.NewGlobalEnvironment(s.out)
+}
+
+func (s *session) time(arg string) {
+	start := time.Now()
+	result := s.evalFile("", arg)
+	elapsed := time.Since(start)
+	if result != nil && result != evaluator.NULL {
+		fmt.Fprintln(s.out, result.Inspect())
+	}
+	fmt.Fprintf(s.out, "took %s\n", elapsed)
+}
+
+func (s *session) help(string) {
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		cmd := commands[name]
+		fmt.Fprintf(s.out, "%-16s %s\n", strings.TrimSpace(name+" "+cmd.usage), cmd.help)
+	}
+}
+
+// incomplete reports whether more lines are needed to finish the input.
+func incomplete(input string) bool {
+	p := parser.New(lexer.New(input))
+	p.ParseProgram()
+	return p.Incomplete()
+}
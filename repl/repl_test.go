@@ -0,0 +1,190 @@
+package repl
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"ljos.app/interpreter/object"
+)
+
+func run(input string) (string, string) {
+	var out, errOut bytes.Buffer
+	Start(io.NopCloser(strings.NewReader(input)), &out, &errOut)
+	return out.String(), errOut.String()
+}
+
+func TestStartEvaluates(t *testing.T) {
+	out, errOut := run("let x = 5;\nx * 2\n\"hi\"\n")
+
+	expected := ">> >> 10\n>> hi\n>> "
+	if out != expected {
+		t.Errorf("wrong output. expected=%q, got=%q", expected, out)
+	}
+	if errOut != "" {
+		t.Errorf("unexpected errors: %q", errOut)
+	}
+}
+
+func TestStartKeepsEnvironment(t *testing.T) {
+	input := `let add = fn(a, b) { a + b };
+let total = add(1, 2);
+total += 10;
+total
+`
+	out, _ := run(input)
+
+	if !strings.HasSuffix(out, "13\n>> 13\n>> ") {
+		t.Errorf("bindings were not kept between inputs, got %q", out)
+	}
+}
+
+func TestStartContinuesIncompleteInput(t *testing.T) {
+	input := "let add = fn(a, b) {\n  a + b\n};\nadd(1,\n2)\n"
+	out, errOut := run(input)
+
+	expected := ">> .. .. >> .. 3\n>> "
+	if out != expected {
+		t.Errorf("wrong output. expected=%q, got=%q", expected, out)
+	}
+	if errOut != "" {
+		t.Errorf("unexpected errors: %q", errOut)
+	}
+}
+
+func TestStartReportsIncompleteInputAtEOF(t *testing.T) {
+	_, errOut := run("let f = fn() {\n 1\n")
+
+	if !strings.HasPrefix(errOut, "3:1: error[P0006]: expected } to close block, got EOF instead") {
+		t.Errorf("expected the unclosed block to be reported, got %q", errOut)
+	}
+}
+
+func TestStartReportsErrors(t *testing.T) {
+	out, errOut := run("let x 5;\nfoo + 1\n1\n")
+
+	if out != ">> >> >> 1\n>> " {
+		t.Errorf("errors should not be printed to out, got %q", out)
+	}
+	expected := `1:7: error[P0001]: expected next token to be =, got INT instead
+  1 | let x 5;
+    |       ^
+1:1: error[R0001]: identifier not found: foo
+  1 | foo + 1
+    | ^~~
+`
+	if errOut != expected {
+		t.Errorf("wrong errors. expected=%q, got=%q", expected, errOut)
+	}
+}
+
+func TestTokensCommand(t *testing.T) {
+	out, errOut := run(":tokens let x\n:nope\nx\n")
+
+	if !strings.Contains(out, "Type:LET Literal:let") || !strings.Contains(out, "Type:IDENTIFIER Literal:x") {
+		t.Errorf("tokens were not printed, got %q", out)
+	}
+	if strings.Contains(out, "Type:EOF") {
+		t.Errorf("EOF should not be printed, got %q", out)
+	}
+	if !strings.HasPrefix(errOut, "unknown command :nope, see :help\n") {
+		t.Errorf("wrong error for unknown command, got %q", errOut)
+	}
+	if !strings.Contains(errOut, "identifier not found: x") {
+		t.Errorf(":tokens should not evaluate its input, got %q", errOut)
+	}
+}
+
+func TestAstCommand(t *testing.T) {
+	out, _ := run(":ast x + 1\n")
+
+	expected := `>> Program 1:1
+  Statements[0]: ExpressionStatement 1:1
+    Expression: InfixExpression 1:1 Operator="+"
+      Left: Identifier 1:1 Value="x"
+      Right: IntegerLiteral 1:5 Value=1
+>> `
+	if out != expected {
+		t.Errorf("wrong output. expected=%q, got=%q", expected, out)
+	}
+}
+
+func TestEnvAndResetCommands(t *testing.T) {
+	out, _ := run("let b = [1];\nlet a = \"x\";\n:env\n:reset\n:env\n")
+
+	expected := ">> >> >> a = x\nb = [1]\n>> >> >> "
+	if out != expected {
+		t.Errorf("wrong output. expected=%q, got=%q", expected, out)
+	}
+}
+
+func TestTypeCommand(t *testing.T) {
+	out, errOut := run(":type 1 + 1.5\n:type [1]\n:type\n")
+
+	if out != ">> FLOAT\n>> ARRAY\n>> >> " {
+		t.Errorf("wrong output, got %q", out)
+	}
+	if errOut != "usage: :type <expr>\n" {
+		t.Errorf("wrong usage error, got %q", errOut)
+	}
+}
+
+func TestLoadCommand(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "lib.hua")
+	if err := os.WriteFile(path, []byte("let double = fn(x) { x * 2 };\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	broken := filepath.Join(dir, "broken.hua")
+	if err := os.WriteFile(broken, []byte("let = 1;\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	out, errOut := run(":load " + path + "\ndouble(21)\n:load " + broken + "\n")
+
+	if out != ">> >> 42\n>> >> " {
+		t.Errorf("wrong output, got %q", out)
+	}
+	if !strings.HasPrefix(errOut, broken+":1:5: error[P0001]") {
+		t.Errorf("errors should refer to the loaded file, got %q", errOut)
+	}
+}
+
+func TestTimeCommand(t *testing.T) {
+	out, _ := run(":time 6 * 7\n")
+
+	if !strings.HasPrefix(out, ">> 42\ntook ") {
+		t.Errorf("wrong output, got %q", out)
+	}
+}
+
+func TestHelpCommand(t *testing.T) {
+	out, _ := run(":help\n")
+
+	for name := range commands {
+		if !strings.Contains(out, name) {
+			t.Errorf("%s missing from help: %q", name, out)
+		}
+	}
+	if !strings.Contains(out, ":load <file>") {
+		t.Errorf("help should show the argument of commands, got %q", out)
+	}
+}
+
+func TestComplete(t *testing.T) {
+	s := &session{env: object.NewEnvironment()}
+	s.env.Set("length", &object.Integer{Value: 1})
+	s.env.Set("fib", &object.Integer{Value: 2})
+
+	got := s.complete("le")
+	if strings.Join(got, ",") != "length,let" {
+		t.Errorf("wrong completions for le, got %q", got)
+	}
+	got = s.complete("f")
+	if strings.Join(got, ",") != "false,fib,fn,for" {
+		t.Errorf("wrong completions for f, got %q", got)
+	}
+}
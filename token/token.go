@@ -1,18 +1,70 @@
 package token
 
+import (
+	"fmt"
+	"sort"
+)
+
 type TokenType string
 
 type Token struct {
 	Type    TokenType
 	Literal string
+	Pos     Position // position of the first character of the token
+	End     Position // position immediately after the last character
+}
+
+func (t Token) Span() Span {
+	return Span{Start: t.Pos, End: t.End}
+}
+
+// Position is a location in a source file. Line and Column are 1-based,
+// Offset is the 0-based byte offset into the source.
+type Position struct {
+	File   string `json:"file,omitempty"`
+	Line   int    `json:"line"`
+	Column int    `json:"column"`
+	Offset int    `json:"offset"`
+}
+
+// IsValid reports whether the position was set by the lexer.
+func (p Position) IsValid() bool {
+	return p.Line > 0
+}
+
+func (p Position) String() string {
+	s := p.File
+	if p.IsValid() {
+		if s != "" {
+			s += ":"
+		}
+		s += fmt.Sprintf("%d:%d", p.Line, p.Column)
+	}
+	if s == "" {
+		s = "-"
+	}
+	return s
+}
+
+// Span is the half-open range [Start, End) of source covered by a token or node.
+type Span struct {
+	Start Position `json:"start"`
+	End   Position `json:"end"`
+}
+
+func (s Span) String() string {
+	return fmt.Sprintf("%s-%d:%d", s.Start, s.End.Line, s.End.Column)
 }
 
 const (
 	ILLEGAL = "ILLEGAL"
 	EOF     = "EOF"
+	COMMENT = "COMMENT" // only found in Lexer.Comments, never returned by NextToken
 
 	IDENTIFIER = "IDENTIFIER"
 	INT        = "INT"
+	FLOAT      = "FLOAT"
+	STRING     = "STRING"
 
 	ASSIGN       = "="
 	MINUS        = "-"
@@ -20,8 +72,18 @@ const (
 	ASTERISK     = "*"
 	SEMICOLON    = ";"
 	COMMA        = ","
+	COLON        = ":"
 	DOT          = "."
 	SLASH        = "/"
+	PERCENT      = "%"
+	POWER        = "**"
+	AMPERSAND    = "&"
+	PIPE         = "|"
+	CARET        = "^"
+	SHIFT_LEFT   = "<<"
+	SHIFT_RIGHT  = ">>"
+	LOGICAL_AND  = "&&"
+	LOGICAL_OR   = "||"
 	BANG         = "!"
 	LESS_THAN    = "<"
 	GREATER_THAN = ">"
@@ -33,6 +95,14 @@ const (
 	MUL_ASSIGN   = "*="
 	MINUS_ASSIGN = "-="
 	PLUS_ASSIGN  = "+="
+	DIV_ASSIGN   = "/="
+	MOD_ASSIGN   = "%="
+	POW_ASSIGN   = "**="
+	AND_ASSIGN   = "&="
+	OR_ASSIGN    = "|="
+	XOR_ASSIGN   = "^="
+	SHL_ASSIGN   = "<<="
+	SHR_ASSIGN   = ">>="
 
 	LPAREN = "("
 	RPAREN = ")"
@@ -48,4 +118,43 @@ const (
 	IF       = "IF"
 	ELSE     = "ELSE"
 	RETURN   = "RETURN"
+	WHILE    = "WHILE"
+	FOR      = "FOR"
+	IN       = "IN"
+	BREAK    = "BREAK"
+	CONTINUE = "CONTINUE"
 )
+
+var keywords = map[string]TokenType{
+	"fn":       FUNCTION,
+	"let":      LET,
+	"true":     TRUE,
+	"false":    FALSE,
+	"if":       IF,
+	"else":     ELSE,
+	"return":   RETURN,
+	"while":    WHILE,
+	"for":      FOR,
+	"in":       IN,
+	"break":    BREAK,
+	"continue": CONTINUE,
+}
+
+// LookupIdent returns the token type of a keyword, or IDENTIFIER if ident is
+// not reserved.
+func LookupIdent(ident string) TokenType {
+	if tok, ok := keywords[ident]; ok {
+		return tok
+	}
+	return IDENTIFIER
+}
+
+// Keywords returns the reserved words of the language in sorted order.
+func Keywords() []string {
+	words := make([]string, 0, len(keywords))
+	for word := range keywords {
+		words = append(words, word)
+	}
+	sort.Strings(words)
+	return words
+}
@@ -0,0 +1,39 @@
+package token
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestLookupIdent(t *testing.T) {
+	tests := []struct {
+		ident    string
+		expected TokenType
+	}{
+		{"let", LET},
+		{"fn", FUNCTION},
+		{"return", RETURN},
+		{"true", TRUE},
+		{"letter", IDENTIFIER},
+		{"x1", IDENTIFIER},
+		{"==", IDENTIFIER},
+	}
+
+	for _, tt := range tests {
+		if got := LookupIdent(tt.ident); got != tt.expected {
+			t.Errorf("LookupIdent(%q) wrong. expected=%q, got=%q", tt.ident, tt.expected, got)
+		}
+	}
+}
+
+func TestKeywords(t *testing.T) {
+	expected := []string{"break", "continue", "else", "false", "fn", "for", "if", "in", "let", "return", "true", "while"}
+	if got := Keywords(); !reflect.DeepEqual(got, expected) {
+		t.Errorf("Keywords() wrong. expected=%v, got=%v", expected, got)
+	}
+	for _, word := range Keywords() {
+		if LookupIdent(word) == IDENTIFIER {
+			t.Errorf("keyword %q is not recognized by LookupIdent", word)
+		}
+	}
+}